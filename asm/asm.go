@@ -0,0 +1,474 @@
+// 19 october 2026
+
+// Package asm assembles parsed a68 source into bytes.
+//
+// As the README promises, assembly happens in a single pass over the statements.
+// A value that refers to a label defined further down is left as zeroes and remembered; once every statement has been assembled, Finish fills those values in.
+package asm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/andlabs/a68/ast"
+	"github.com/andlabs/a68/core"
+	"github.com/andlabs/a68/parser"
+	"github.com/andlabs/a68/scanner"
+	"github.com/andlabs/a68/token"
+)
+
+// Chunk is a run of assembled bytes at consecutive addresses.
+type Chunk struct {
+	Section	string
+	Addr		uint32
+	Data		[]byte
+}
+
+// ListLine is what assembling one statement produced, for listings.
+type ListLine struct {
+	Pos		token.Pos		// of the statement
+	Addr		uint32
+	Data		[]byte
+}
+
+// span is a range of bytes in a chunk.
+// Chunks grow as statements are assembled, so these are kept as offsets and only turned into slices by Finish.
+type span struct {
+	chunk	int
+	off		int
+	n		int
+}
+
+// fixup is a value that could not be computed when its statement was assembled.
+type fixup struct {
+	kind		core.FixupKind
+	x		*core.Expr		// with every name that was known at the time bound
+	scope	scope			// to look up the rest of the names from
+	chunk	int
+	off		int
+	pc		uint32
+}
+
+type listSpan struct {
+	pos		token.Pos
+	addr		uint32
+	span		span
+}
+
+// Assembler assembles statements in order.
+type Assembler struct {
+	// IncludePaths are the directories searched for the files named by .include and .incbin, after the directory of the file with the directive.
+	IncludePaths	[]string
+
+	// Mode is the scanner mode that source files are parsed with.
+	Mode		scanner.Mode
+
+	// Encoding is the encoding of source files, as scanner.Transcode takes it.
+	Encoding	string
+
+	fset		*token.FileSet
+	errs		*scanner.ErrorCollector
+
+	sections	map[string]*section
+	sec		*section			// the current section
+	atZero	*section			// the section that started at $0 without an .org, if any
+	chunks	[]Chunk
+	chunkPos	[]token.Pos		// of the first statement in each chunk, for errors
+	reserved	[]extent			// the space reserved in bss sections, for checkOverlaps
+	symbols	*symbolTable
+	fixups	[]fixup
+	lines	[]listSpan
+
+	enc		core.Encoder
+
+	aliases	parser.RegAliases
+	macroNames	parser.Macros
+	macros	map[string]*ast.MacroStmt
+	expansions	int				// the number of macro expansions so far, which makes their local labels unique
+	expanding	[]token.Pos		// the invocations of the macros being expanded, innermost last
+	p		*parser.Parser		// of the file being assembled
+	conds	[]cond
+	condBase	int				// the number of conds that belong to the files that include the one being assembled
+	files		[]File
+	including	[]string			// the fileKeys of the files being assembled, innermost last
+	once		map[string]bool	// the fileKeys of the files that used .once
+}
+
+// New returns a new Assembler that reports errors to errs.
+func New(fset *token.FileSet, errs *scanner.ErrorCollector) *Assembler {
+	a := &Assembler{
+		fset:		fset,
+		errs:		errs,
+		symbols:	newSymbolTable(fset),
+		sections:	make(map[string]*section),
+		aliases:	parser.RegAliases{},
+		macroNames:	parser.Macros{},
+		macros:	make(map[string]*ast.MacroStmt),
+		once:	make(map[string]bool),
+	}
+	for _, s := range standardSections {
+		a.sections[s.name] = &section{
+			name:	s.name,
+			bss:		s.bss,
+			chunk:	-1,
+			reserved:	-1,
+		}
+	}
+	a.sec = a.sections["code"]
+	a.enc.Eval = a.resolve
+	return a
+}
+
+func (a *Assembler) errorf(pos token.Pos, format string, args ...interface{}) {
+	a.errs.ReportError(pos, fmt.Errorf(format, args...))
+}
+
+// Define defines name as an equate with the given value, as the -D option of a68 does.
+// If Mode has FoldSymbols, name is lowercased, as the scanner does to the names in the source; so Mode has to be set first.
+func (a *Assembler) Define(name string, value uint64) {
+	if a.Mode & scanner.FoldSymbols != 0 {
+		name = strings.ToLower(name)
+	}
+	a.symbols.define(&symbol{
+		name:	name,
+		kind:		equateSymbol,
+		value:	value,
+	})
+}
+
+// evalHandler evaluates expressions for an Assembler.
+type evalHandler struct {
+	a		*Assembler
+	scope	scope
+	pc		uint32			// the value of ., which is the address of the statement being assembled
+	pending	bool				// whether undefined names may still be defined further down
+	final		bool				// whether this is Finish, when undefined names never will be
+}
+
+func (h *evalHandler) LookupName(name string) (uint64, bool) {
+	if name == "." {
+		return uint64(h.pc), true
+	}
+	s := h.a.symbols.lookup(name, h.scope)
+	if s == nil || s.kind == regAliasSymbol {
+		return 0, false
+	}
+	if s.kind == variableSymbol && h.final {
+		// the value it had where it was used is gone
+		return 0, false
+	}
+	return s.value, true
+}
+
+func (h *evalHandler) NamePending(name string) bool {
+	return h.pending && h.a.symbols.pending(name, h.scope)
+}
+
+func (h *evalHandler) ReportError(pos token.Pos, err error) {
+	if e, ok := err.(core.UnknownNameError); ok {
+		err = h.unknownName(string(e))
+	}
+	h.a.errs.ReportError(pos, err)
+}
+
+func (h *evalHandler) unknownName(name string) error {
+	if s := h.a.symbols.lookup(name, h.scope); s != nil {
+		if s.kind == regAliasSymbol {
+			return fmt.Errorf("%s is a register alias, not a value", name)
+		}
+		return fmt.Errorf("variable %s must be assigned before it is used", name)
+	}
+	if _, forward, ok := nameless(name); ok {
+		if forward {
+			return fmt.Errorf("no + label for %s to refer to after it", name)
+		}
+		return fmt.Errorf("no - label for %s to refer to before it", name)
+	}
+	if h.final {
+		return fmt.Errorf("undefined label %q", name)
+	}
+	return fmt.Errorf("%q must be defined before it is used here", name)
+}
+
+func (a *Assembler) handler(pending bool) *evalHandler {
+	return &evalHandler{
+		a:		a,
+		scope:	a.symbols.scope,
+		pc:		a.sec.pc,
+		pending:	pending,
+	}
+}
+
+// resolve evaluates x as far as it can be now; names that are not defined yet are assumed to be labels further down.
+func (a *Assembler) resolve(x *core.Expr) core.EvalResult {
+	return x.Resolve(a.handler(true))
+}
+
+// evalNow evaluates x, which needs a value right away, as the value of an equate does.
+func (a *Assembler) evalNow(x *core.Expr) (uint64, bool) {
+	r := x.Resolve(a.handler(false))
+	return r.Value, r.Status == core.EvalResolved
+}
+
+// emit adds b, which came from the statement at pos, at the location counter of the current section, which must not be a bss section.
+func (a *Assembler) emit(pos token.Pos, b []byte) span {
+	a.place(pos)
+	sec := a.sec
+	if sec.chunk < 0 || a.chunks[sec.chunk].Addr + uint32(len(a.chunks[sec.chunk].Data)) != sec.pc {
+		sec.chunk = len(a.chunks)
+		a.chunks = append(a.chunks, Chunk{
+			Section:	sec.name,
+			Addr:		sec.pc,
+		})
+		a.chunkPos = append(a.chunkPos, pos)
+	}
+	c := &a.chunks[sec.chunk]
+	sp := span{
+		chunk:	sec.chunk,
+		off:		len(c.Data),
+		n:		len(b),
+	}
+	c.Data = append(c.Data, b...)
+	sec.pc += uint32(len(b))
+	return sp
+}
+
+// addFixups records the fixups of an encoded value that was emitted to sp from the statement at addr.
+// It is called after emit has advanced the location counter, so . is bound to addr instead.
+func (a *Assembler) addFixups(sp span, addr uint32, fixups []core.Fixup) {
+	h := a.handler(true)
+	h.pc = addr
+	for _, f := range fixups {
+		a.fixups = append(a.fixups, fixup{
+			kind:		f.Kind,
+			x:		f.X.Bind(h.LookupName),
+			scope:	h.scope,
+			chunk:	sp.chunk,
+			off:		sp.off + f.Offset,
+			pc:		f.PC,
+		})
+	}
+}
+
+func (a *Assembler) list(pos token.Pos, addr uint32, sp span) {
+	a.lines = append(a.lines, listSpan{
+		pos:		pos,
+		addr:	addr,
+		span:	sp,
+	})
+}
+
+// AssembleFile reads the named source file and assembles it.
+func (a *Assembler) AssembleFile(filename string) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		a.errorf(token.NoPos, "%v", err)
+		return
+	}
+	a.AssembleSource(filename, data)
+}
+
+// AssembleSource assembles src, which is registered in the FileSet under filename.
+// src is first converted to UTF-8 from Encoding.
+func (a *Assembler) AssembleSource(filename string, src []byte) {
+	a.assembleSource(filename, src, token.NoPos)
+}
+
+// assembleSource is AssembleSource for a file that came from the directive at from, or from the command line if from is NoPos.
+func (a *Assembler) assembleSource(filename string, src []byte, from token.Pos) {
+	src, err := scanner.Transcode(src, a.Encoding)
+	if err != nil {
+		a.errorf(token.NoPos, "%s: %v", filename, err)
+		return
+	}
+	key := fileKey(filename)
+	a.including = append(a.including, key)
+	defer func() {
+		a.including = a.including[:len(a.including) - 1]
+	}()
+
+	p := parser.NewParser(a.fset, filename, src, a.Mode, a.aliases, a.macroNames)
+	if from.IsValid() {
+		a.fset.SetOrigin(p.File(), token.Origin{
+			Kind:	token.Included,
+			Pos:		from,
+		})
+	}
+	a.files = append(a.files, File{
+		File:	p.File(),
+		Data:	src,
+	})
+	a.run(p)
+}
+
+// run assembles the statements of p.
+// Each line is assembled before the next is parsed, so that the lines after a .include can use the register aliases and macros it defined.
+func (a *Assembler) run(p *parser.Parser) {
+	prev, base := a.p, a.condBase
+	a.p, a.condBase = p, len(a.conds)
+	defer func() {
+		a.p, a.condBase = prev, base
+	}()
+	for {
+		stmts, ok := p.ParseLine()
+		if !ok {
+			break
+		}
+		for _, s := range stmts {
+			a.assemble(s)
+		}
+	}
+	// parse errors go through ReportError so that they show how the file was included too
+	if list, ok := p.Err().(scanner.ErrorList); ok {
+		for _, e := range list {
+			a.errs.ReportError(p.File().Pos(e.Pos.Offset), errors.New(e.Msg))
+		}
+	}
+	a.checkConds()
+}
+
+func (a *Assembler) assemble(s ast.Stmt) {
+	switch s := s.(type) {
+	case *ast.BadStmt:
+		// already reported by the parser
+	case *ast.LabelStmt:
+		a.label(s)
+	case *ast.AssignStmt:
+		a.assign(s)
+	case *ast.RegAliasStmt:
+		a.regAlias(s)
+	case *ast.InstrStmt:
+		a.instr(s)
+	case *ast.DirectiveStmt:
+		a.directive(s)
+	case *ast.MacroStmt:
+		a.defineMacro(s)
+	case *ast.MacroCallStmt:
+		a.expand(s)
+	default:
+		panic(fmt.Sprintf("unknown statement type %T", s))
+	}
+}
+
+func (a *Assembler) define(s *symbol) {
+	if err := a.symbols.define(s); err != nil {
+		a.errs.ReportError(s.pos, err)
+	}
+}
+
+func (a *Assembler) label(s *ast.LabelStmt) {
+	a.place(s.LabelPos)
+	a.list(s.LabelPos, a.sec.pc, span{})
+	kind := labelSymbol
+	switch s.Kind {
+	case ast.LocalLabel:
+		kind = localSymbol
+	case ast.NextLabel, ast.PrevLabel:
+		kind = namelessSymbol
+	}
+	a.define(&symbol{
+		name:	s.Name,
+		kind:		kind,
+		value:	uint64(a.sec.pc),
+		pos:		s.LabelPos,
+	})
+}
+
+func (a *Assembler) assign(s *ast.AssignStmt) {
+	v, ok := a.evalNow(s.Value.X)
+	if !ok {
+		return
+	}
+	kind := variableSymbol
+	if s.Equ {
+		kind = equateSymbol
+	}
+	a.define(&symbol{
+		name:	s.Name,
+		kind:		kind,
+		value:	v,
+		pos:		s.NamePos,
+	})
+}
+
+func (a *Assembler) regAlias(s *ast.RegAliasStmt) {
+	a.define(&symbol{
+		name:	s.Name,
+		kind:		regAliasSymbol,
+		reg:		s.Reg,
+		pos:		s.NamePos,
+	})
+}
+
+func (a *Assembler) instr(s *ast.InstrStmt) {
+	op, ok := core.LookupOpcode(strings.ToLower(s.Name))
+	if !ok {
+		a.errorf(s.NamePos, "unknown instruction %s", s.Name)
+		return
+	}
+	size, ok := core.ParseSize(s.Size)
+	if !ok {
+		a.errorf(s.NamePos, "invalid size suffix .%s", s.Size)
+		return
+	}
+	ops := make([]core.Operand, len(s.Operands))
+	for i, o := range s.Operands {
+		if !a.operand(&ops[i], o) {
+			return
+		}
+	}
+	if !a.checkData(s.NamePos) {
+		return
+	}
+	if a.sec.pc & 1 != 0 {
+		a.errorf(s.NamePos, "instruction at odd address $%X", a.sec.pc)
+	}
+	a.enc.Reset(a.sec.pc)
+	if err := op.Encode(&a.enc, size, ops); err != nil {
+		pos := s.NamePos
+		switch e := err.(type) {
+		case *core.OperandError:
+			pos = ops[e.N].Pos
+		case *core.ValueError:
+			pos = e.Pos
+		}
+		a.errs.ReportError(pos, err)
+		return
+	}
+	addr := a.sec.pc
+	sp := a.emit(s.NamePos, a.enc.Bytes)
+	a.addFixups(sp, addr, a.enc.Fixups)
+	a.list(s.NamePos, addr, sp)
+}
+
+// Finish fills in the values that refer to labels defined after them, reporting every use of a label that was never defined and every section that overlaps another, and returns the assembled bytes and the listing records of every statement.
+func (a *Assembler) Finish() (chunks []Chunk, lines []ListLine) {
+	for _, f := range a.fixups {
+		h := &evalHandler{
+			a:		a,
+			scope:	f.scope,
+			final:	true,
+		}
+		v, ok := f.x.Evaluate(h)
+		if !ok {
+			continue
+		}
+		if err := f.kind.Put(a.chunks[f.chunk].Data[f.off:], v, f.pc); err != nil {
+			a.errs.ReportError(f.x.Pos(), err)
+		}
+	}
+	a.checkOverlaps()
+	lines = make([]ListLine, len(a.lines))
+	for i, l := range a.lines {
+		lines[i] = ListLine{
+			Pos:		l.pos,
+			Addr:	l.addr,
+		}
+		if l.span.n != 0 {
+			lines[i].Data = a.chunks[l.span.chunk].Data[l.span.off:l.span.off + l.span.n]
+		}
+	}
+	return a.chunks, lines
+}
@@ -0,0 +1,279 @@
+// 19 october 2026
+package asm
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/andlabs/a68/scanner"
+	"github.com/andlabs/a68/token"
+)
+
+func testAssemble(t *testing.T, src string) ([]Chunk, []ListLine, scanner.ErrorList) {
+	fset := token.NewFileSet()
+	errs := scanner.NewErrorCollector(fset)
+	a := New(fset, errs)
+	a.AssembleSource("test.s", []byte(src))
+	chunks, lines := a.Finish()
+	return chunks, lines, errs.Errors()
+}
+
+func hexChunks(chunks []Chunk) string {
+	s := make([]string, len(chunks))
+	for i, c := range chunks {
+		s[i] = fmt.Sprintf("%X:%X", c.Addr, c.Data)
+	}
+	return strings.Join(s, " ")
+}
+
+func TestAssemble(t *testing.T) {
+	for _, tc := range []struct {
+		src		string
+		want	string
+	}{
+		{"nop\n", "0:4E71"},
+		{"start:\tmove.l\td0,d1\n\tbra\tstart\n", "0:220060FC"},
+		{"\tbra\tend\n\tnop\nend:\trts\n", "0:600000044E714E75"},
+		{"\tbeq.s\tend\n\tnop\nend:\trts\n", "0:67024E714E75"},
+		{"\tjmp\tfar\n\tjmp\tnear\nnear .equ $1000\nfar .equ $123456\n", "0:4EF9001234564EF900001000"},
+		{"near = $1000\n\tjmp\tnear\n", "0:4EF81000"},
+		{"\tlea\ttable(pc),a0\n\tnop\ntable:\t.dc.w\ttable, .\n", "0:41FA00044E7100060006"},
+		{"\t.dc.b\t\"hi\", 0, -1\n\t.dc.l\tend\nend:\n", "0:6869 00FF 00000008"},
+		{"\t.ds.w\t2\n\t.dc.b\t1\n", "0:0000000001"},
+		{"x = 1\n\t.dc.w\tfwd + x\nx = 2\n\t.dc.w\tfwd + x\nfwd:\n", "0:00050006"},
+		{"\tmoveq\t#val,d0\n\taddq.w\t#val,d1\nval .equ 3\n", "0:70035641"},
+		{"\t.dc.w\t0\n\t.dc.l\tfwd - .\nfwd:\n", "0:0000 00000004"},
+		{"\tmove.w\t. + fwd,d0\nfwd:\n", "0:3039 00000006"},
+	} {
+		chunks, _, errs := testAssemble(t, tc.src)
+		if len(errs) != 0 {
+			t.Errorf("%q: unexpected errors: %v", tc.src, errs)
+			continue
+		}
+		want := strings.ReplaceAll(tc.want, " ", "")
+		if got := hexChunks(chunks); got != want {
+			t.Errorf("%q: wrong output:\ngot  %s\nwant %s", tc.src, got, want)
+		}
+	}
+}
+
+func TestAssembleErrors(t *testing.T) {
+	for _, tc := range []struct {
+		src		string
+		want	[]string
+	}{
+		{"\tbra\tnowhere\n\t.dc.l\tnowhere + 1\n\tjmp\telsewhere\n", []string{
+			`test.s:1:6: undefined label "nowhere"`,
+			`test.s:2:8: undefined label "nowhere"`,
+			`test.s:3:6: undefined label "elsewhere"`,
+		}},
+		{"x .equ y\ny:\n", []string{
+			`test.s:1:8: "y" must be defined before it is used here`,
+		}},
+		{"a:\na:\nb .equ 1\nb .equ 2\n", []string{
+			"test.s:2:1: a already defined as a label at test.s:1:1",
+			"test.s:4:1: b already defined as an equate at test.s:3:1",
+		}},
+		{"\tlea\td0,a0\n", []string{
+			"test.s:1:6: lea cannot take dn as operand 1",
+		}},
+		{"\tbra.s\tnext\nnext:\n", []string{
+			"test.s:1:8: short branch cannot go to the next instruction",
+		}},
+		{"\t.dc.b\t1\n\tnop\n\t.dc.w\t1\n", []string{
+			"test.s:2:2: instruction at odd address $1",
+			"test.s:3:2: .dc.w at odd address $3",
+		}},
+		{"\t.dc.b\t0, 256\n\t.dc.w\t\"no\"\n\t.dc.b\tbig\nbig .equ 300\n", []string{
+			"test.s:1:11: value 256 does not fit in a byte",
+			"test.s:2:8: strings can only be used with .dc.b",
+			"test.s:3:8: value 300 does not fit in a byte",
+		}},
+	} {
+		_, _, errs := testAssemble(t, tc.src)
+		got := make([]string, len(errs))
+		for i, e := range errs {
+			got[i] = e.Error()
+		}
+		if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+			t.Errorf("%q: wrong errors:\ngot  %q\nwant %q", tc.src, got, tc.want)
+		}
+	}
+}
+
+func TestListLines(t *testing.T) {
+	_, lines, errs := testAssemble(t, "start:\tmove.w\t#fwd,d0\n\t.dc.b\t1,2\nfwd:\n")
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	var got []string
+	for _, l := range lines {
+		got = append(got, fmt.Sprintf("%d %X %X", l.Pos, l.Addr, l.Data))
+	}
+	// positions are file offsets plus the base of 1
+	want := []string{"1 0 ", "8 0 303C0006", "24 4 0102", "34 6 "}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("wrong list lines:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestSymbols(t *testing.T) {
+	for _, tc := range []struct {
+		src		string
+		want	string
+	}{
+		// each global label has its own @local labels
+		{"one:\n@loop:\tbra\t@loop\n\tbra\t@end\n@end:\ntwo:\n@loop:\tbra\t@loop\n", "0:60FE6000000260FE"},
+		// nameless labels
+		{"-\tnop\n-\tbra\t:--\n\tbra\t:+\n\tbra\t:++\n+\tnop\n+\tnop\n", "0:4E7160FC60000006600000044E714E71"},
+		{"-\tbra\t:-\n", "0:60FE"},
+		// register aliases
+		{"ptr .equr a0\ncount .equr d1\n\tmove.l\t(ptr)+,count\n\tmovem.l\tcount/ptr,-(sp)\n\tmove.b\t2(ptr,count.w),d0\n", "0:221848E74080 10301002"},
+		// variables can be assigned again
+		{"n = 1\nn = n + 1\n\t.dc.b\tn\n", "0:02"},
+	} {
+		chunks, _, errs := testAssemble(t, tc.src)
+		if len(errs) != 0 {
+			t.Errorf("%q: unexpected errors: %v", tc.src, errs)
+			continue
+		}
+		want := strings.ReplaceAll(tc.want, " ", "")
+		if got := hexChunks(chunks); got != want {
+			t.Errorf("%q: wrong output:\ngot  %s\nwant %s", tc.src, got, want)
+		}
+	}
+}
+
+func TestSymbolErrors(t *testing.T) {
+	for _, tc := range []struct {
+		src		string
+		want	[]string
+	}{
+		{"one:\n@x:\ntwo:\n\tbra\t@x\n@x:\n@x:\n", []string{
+			"test.s:6:1: @x already defined at test.s:5:1",
+		}},
+		{"one:\n\tbra\t@x\ntwo:\n@x:\n", []string{
+			`test.s:2:6: undefined label "@x"`,
+		}},
+		{"\tbra\t:-\n-\n", []string{
+			"test.s:1:6: no - label for :- to refer to before it",
+		}},
+		{"+\none:\n\tbra\t:-\n\tbra\t:+\ntwo:\n+\n", []string{
+			"test.s:3:6: no - label for :- to refer to before it",
+			"test.s:4:6: no + label for :+ to refer to after it",
+		}},
+		{"\t.dc.w\tv\nv = 1\n", []string{
+			"test.s:1:8: variable v must be assigned before it is used",
+		}},
+		{"x = 1\nx .equ 2\ny .equ 1\ny = 2\nz:\nz = 3\n", []string{
+			"test.s:2:1: x already defined as a variable at test.s:1:1",
+			"test.s:4:1: y already defined as an equate at test.s:3:1",
+			"test.s:6:1: z already defined as a label at test.s:5:1",
+		}},
+		{"r .equr d0\nr .equr d1\n", []string{
+			"test.s:2:1: r already defined as a register alias at test.s:1:1",
+		}},
+	} {
+		_, _, errs := testAssemble(t, tc.src)
+		got := make([]string, len(errs))
+		for i, e := range errs {
+			got[i] = e.Error()
+		}
+		if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+			t.Errorf("%q: wrong errors:\ngot  %q\nwant %q", tc.src, got, tc.want)
+		}
+	}
+}
+
+func TestDefine(t *testing.T) {
+	fset := token.NewFileSet()
+	errs := scanner.NewErrorCollector(fset)
+	a := New(fset, errs)
+	a.Define("PAL", 1)
+	a.AssembleSource("test.s", []byte("\t.dc.b\tPAL\nPAL .equ 0\n"))
+	chunks, _ := a.Finish()
+	if got := hexChunks(chunks); got != "0:01" {
+		t.Errorf("wrong output: got %s, want 0:01", got)
+	}
+	want := "test.s:2:1: PAL already defined as an equate with -D"
+	if err := errs.Err(); err == nil || err.Error() != want {
+		t.Errorf("wrong error: got %v, want %s", err, want)
+	}
+}
+
+func TestDefineFoldSymbols(t *testing.T) {
+	fset := token.NewFileSet()
+	errs := scanner.NewErrorCollector(fset)
+	a := New(fset, errs)
+	a.Mode = scanner.FoldSymbols
+	a.Define("PAL", 1)
+	a.AssembleSource("test.s", []byte("\t.ifdef\tPAL\n\t.dc.b\tPAL, Pal\n\t.endif\n"))
+	chunks, _ := a.Finish()
+	if err := errs.Err(); err != nil {
+		t.Fatalf("unexpected errors: %v", err)
+	}
+	if got := hexChunks(chunks); got != "0:0101" {
+		t.Errorf("wrong output: got %s, want 0:0101", got)
+	}
+}
+
+func TestSections(t *testing.T) {
+	for _, tc := range []struct {
+		src		string
+		want	string
+	}{
+		// each section has its own location counter
+		{"\t.org\t$100\n\tnop\n\t.data\n\t.org\t$200\nmsg:\t.dc.b\t1\n\t.code\n\tlea\tmsg,a0\n", "100:4E7141F80200 200:01"},
+		// bss sections only reserve space
+		{"\t.bss\n\t.org\t$FF0000\nvar1:\t.ds.l\t1\nvar2:\t.ds.w\t1\n\t.code\n\tmove.w\tvar2,d0\n\t.dc.l\t.\n", "0:303900FF000400000006"},
+		{"\t.section\tvectors\n\t.dc.l\tstart\n\t.section\tram, bss\n\t.org\t$FF0000\nbuf:\t.ds.b\t16\n\t.code\n\t.org\t$400\nstart:\tlea\tbuf,a0\n", "0:00000400 400:41F900FF0000"},
+		// . is the location in the current section
+		{"\t.data\n\t.org\t$10\n\t.dc.w\t.\n\t.code\n\t.dc.w\t.\n", "10:0010 0:0000"},
+	} {
+		chunks, _, errs := testAssemble(t, tc.src)
+		if len(errs) != 0 {
+			t.Errorf("%q: unexpected errors: %v", tc.src, errs)
+			continue
+		}
+		if got := hexChunks(chunks); got != tc.want {
+			t.Errorf("%q: wrong output:\ngot  %s\nwant %s", tc.src, got, tc.want)
+		}
+	}
+}
+
+func TestSectionErrors(t *testing.T) {
+	for _, tc := range []struct {
+		src		string
+		want	[]string
+	}{
+		{"\t.bss\n\tnop\n\t.dc.b\t1\n", []string{
+			"test.s:2:2: cannot store instructions or data in bss section bss",
+			"test.s:3:2: cannot store instructions or data in bss section bss",
+		}},
+		{"\tnop\n\t.data\n\t.org\t0\n\t.dc.w\t1\n", []string{
+			"test.s:4:2: section data at $0 overlaps section code from test.s:1:2",
+		}},
+		{"\t.data\n\t.dc.b\t1\n\t.code\n\tnop\n\t.bss\nvar:\t.ds.l\t1\n", []string{
+			"test.s:4:2: section code needs an .org; only one section can start at $0 without one, and section data already does",
+			"test.s:6:1: section bss needs an .org; only one section can start at $0 without one, and section data already does",
+		}},
+		{"\t.org\t$10\n\tnop\n\tnop\n\t.bss\n\t.org\t$E\nvar:\t.ds.l\t1\n\t.org\t$20\n\t.ds.l\t1\n", []string{
+			"test.s:2:2: section code at $10 overlaps section bss from test.s:6:6",
+		}},
+		{"\t.section\tram, bss\n\t.section\tram\n\t.section\tdata, bss\n\t.section\tram, zero\n\t.org\tlater\nlater:\n", []string{
+			"test.s:3:2: section data is not a bss section (a standard section)",
+			"test.s:4:16: unknown section flag zero",
+			`test.s:5:7: "later" must be defined before it is used here`,
+		}},
+	} {
+		_, _, errs := testAssemble(t, tc.src)
+		got := make([]string, len(errs))
+		for i, e := range errs {
+			got[i] = e.Error()
+		}
+		if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+			t.Errorf("%q: wrong errors:\ngot  %q\nwant %q", tc.src, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// 19 october 2026
+package asm
+
+import (
+	"github.com/andlabs/a68/ast"
+	"github.com/andlabs/a68/token"
+)
+
+// Conditional assembly is written
+//
+//	.if expr
+//	...
+//	.elseif expr
+//	...
+//	.else
+//	...
+//	.endif
+//
+// with any number of .elseif branches and an optional .else; .ifdef name and .ifndef name can take the place of .if.
+// Only the first branch whose condition is true is assembled; the other branches are skipped without being parsed.
+// Since there is only one pass, conditions can only use names defined before them; defining a name after an .ifdef or .ifndef that tested it is an error.
+// Each directive has to be the last statement on its line, and in a branch that is skipped, they are only recognized at the start of a line.
+
+// cond is a .if that has not been closed by .endif yet.
+type cond struct {
+	pos		token.Pos	// of the .if
+	name	string		// .if, .ifdef, or .ifndef
+	taken	bool		// whether a branch has been assembled
+	els		bool		// whether .else has been seen
+}
+
+func (a *Assembler) condDirective(s *ast.DirectiveStmt, name string) {
+	if name != ".endif" && !a.p.LineEnded() {
+		a.errorf(s.NamePos, "%s must be the last statement on its line", s.Name)
+		return
+	}
+	switch name {
+	case ".if", ".ifdef", ".ifndef":
+		a.conds = append(a.conds, cond{
+			pos:		s.NamePos,
+			name:	s.Name,
+		})
+		a.branch(s, name)
+	case ".elseif", ".else":
+		if len(a.conds) == a.condBase {
+			a.errorf(s.NamePos, "%s without .if", s.Name)
+			return
+		}
+		c := &a.conds[len(a.conds) - 1]
+		if c.els {
+			a.errorf(s.NamePos, "%s after .else", s.Name)
+		}
+		if name == ".else" {
+			c.els = true
+		}
+		if c.taken {
+			// the branch that was taken ends here
+			a.skip()
+			return
+		}
+		a.branch(s, name)
+	case ".endif":
+		if len(s.Args) != 0 {
+			a.errorf(s.Args[0].Pos(), ".endif takes no arguments")
+		}
+		if len(a.conds) == a.condBase {
+			a.errorf(s.NamePos, ".endif without .if")
+			return
+		}
+		a.conds = a.conds[:len(a.conds) - 1]
+	}
+}
+
+// branch assembles the branch that s starts if its condition is true, and skips it otherwise.
+// A condition that cannot be evaluated counts as false, so that only one branch is assembled.
+func (a *Assembler) branch(s *ast.DirectiveStmt, name string) {
+	if a.condition(s, name) {
+		a.conds[len(a.conds) - 1].taken = true
+		return
+	}
+	a.skip()
+}
+
+func (a *Assembler) condition(s *ast.DirectiveStmt, name string) bool {
+	if name == ".else" {
+		if len(s.Args) != 0 {
+			a.errorf(s.Args[0].Pos(), ".else takes no arguments")
+		}
+		return true
+	}
+	if len(s.Args) != 1 {
+		what := "condition"
+		if name == ".ifdef" || name == ".ifndef" {
+			what = "name"
+		}
+		a.errorf(s.NamePos, "%s takes a %s", s.Name, what)
+		return false
+	}
+	x, ok := s.Args[0].(*ast.Expr)
+	if !ok {
+		if _, bad := s.Args[0].(*ast.BadArg); !bad {
+			a.errorf(s.Args[0].Pos(), "%s needs an expression, not a string", s.Name)
+		}
+		return false
+	}
+	if name == ".ifdef" || name == ".ifndef" {
+		n, ok := x.X.Name()
+		if !ok {
+			a.errorf(x.Pos(), "%s needs a name", s.Name)
+			return false
+		}
+		defined := a.isDefined(n)
+		if !defined {
+			a.symbols.test(n, s.Name, s.NamePos)
+		}
+		return defined == (name == ".ifdef")
+	}
+	v, ok := a.evalNow(x.X)
+	return ok && v != 0
+}
+
+// isDefined returns whether name refers to something at this point in the source, for .ifdef.
+func (a *Assembler) isDefined(name string) bool {
+	if _, ok := a.macros[name]; ok {
+		return true
+	}
+	return a.symbols.lookup(name, a.symbols.scope) != nil
+}
+
+// skip skips the lines of a branch that is not assembled, up to the .elseif, .else, or .endif that ends it.
+func (a *Assembler) skip() {
+	depth := 0
+	found := a.p.SkipLines(func(word string) bool {
+		switch word {
+		case ".if", ".ifdef", ".ifndef":
+			depth++
+		case ".elseif", ".else":
+			return depth == 0
+		case ".endif":
+			if depth == 0 {
+				return true
+			}
+			depth--
+		}
+		return false
+	})
+	if !found {
+		c := a.conds[len(a.conds) - 1]
+		a.errorf(c.pos, "missing .endif for %s", c.name)
+		a.conds = a.conds[:len(a.conds) - 1]
+	}
+}
+
+// checkConds reports each .if that the file being assembled did not close, and forgets it.
+func (a *Assembler) checkConds() {
+	for len(a.conds) > a.condBase {
+		c := a.conds[len(a.conds) - 1]
+		a.errorf(c.pos, "missing .endif for %s", c.name)
+		a.conds = a.conds[:len(a.conds) - 1]
+	}
+}
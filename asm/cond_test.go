@@ -0,0 +1,127 @@
+// 19 october 2026
+package asm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/andlabs/a68/scanner"
+	"github.com/andlabs/a68/token"
+)
+
+func TestConditionals(t *testing.T) {
+	const src = "\t.ifdef\tPAL\n" +
+		"\t.dc.b\t50\n" +
+		"\t.elseif\tREGION == 1\n" +
+		"\t.dc.b\t60\n" +
+		"\t.else\n" +
+		"\t.dc.b\t0\n" +
+		"\t.endif\n" +
+		"\t.ifdef\tDEBUG\n" +
+		"\t.dc.b\t$DB\n" +
+		"\t.elseif\t0\n" +
+		"\t.if\tnot defined :: this is never parsed\n" +
+		"\t.else\n" +
+		"\t.endif\n" +
+		"\t.endif\n"
+	for _, tc := range []struct {
+		defines	map[string]uint64
+		want	string
+	}{
+		{map[string]uint64{"PAL": 0}, "0:32"},
+		{map[string]uint64{"REGION": 1}, "0:3C"},
+		{map[string]uint64{"REGION": 2, "DEBUG": 0}, "0:00DB"},
+	} {
+		fset := token.NewFileSet()
+		errs := scanner.NewErrorCollector(fset)
+		a := New(fset, errs)
+		for name, v := range tc.defines {
+			a.Define(name, v)
+		}
+		a.AssembleSource("test.s", []byte(src))
+		chunks, _ := a.Finish()
+		if err := errs.Err(); err != nil {
+			t.Errorf("%v: unexpected errors: %v", tc.defines, err)
+			continue
+		}
+		if got := hexChunks(chunks); got != tc.want {
+			t.Errorf("%v: wrong output: got %s, want %s", tc.defines, got, tc.want)
+		}
+	}
+}
+
+func TestConditionalMacros(t *testing.T) {
+	chunks, _, errs := testAssemble(t, "\t.macro\tfill n, v=0\n" +
+		"\t.if\t\\n > 0\n" +
+		"\t.dc.b\t\\v\n" +
+		"\tfill\t\\n-1, \\v\n" +
+		"\t.endif\n" +
+		"\t.endm\n" +
+		"\tfill\t3, 7\n")
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if got := hexChunks(chunks); got != "0:070707" {
+		t.Errorf("wrong output: got %s, want 0:070707", got)
+	}
+}
+
+func TestConditionalErrors(t *testing.T) {
+	for _, tc := range []struct {
+		src		string
+		want	[]string
+	}{
+		{"\t.if\tlater\n\t.endif\nlater:\n", []string{
+			`test.s:1:6: "later" must be defined before it is used here`,
+		}},
+		{"\t.else\n\t.elseif\t1\n\t.endif\n", []string{
+			"test.s:1:2: .else without .if",
+			"test.s:2:2: .elseif without .if",
+			"test.s:3:2: .endif without .if",
+		}},
+		{"\t.if\t1\n\t.else\n\t.else\n\t.endif\n", []string{
+			"test.s:3:2: .else after .else",
+		}},
+		{"\t.if\t0\n\t.if\t1\n\t.endif\n", []string{
+			"test.s:1:2: missing .endif for .if",
+		}},
+		{"\t.ifdef\tlater\n\t.endif\n\t.ifndef\tm\n\t.endif\nlater:\n\t.macro\tm\n\t.endm\n", []string{
+			"test.s:5:1: later is defined after the .ifdef at test.s:1:2 that tested it",
+			"test.s:6:9: m is defined after the .ifndef at test.s:3:2 that tested it",
+		}},
+		{"one:\n\t.ifdef\t@x\n\t.endif\ntwo:\n@x:\none:\n@x:\n", []string{
+			"test.s:6:1: one already defined as a label at test.s:1:1",
+			"test.s:7:1: @x is defined after the .ifdef at test.s:2:2 that tested it",
+		}},
+		{"\t.ifdef\t1\n\t.endif\n\t.if\t1 :: nop\n\t.if\n\t.endif\n", []string{
+			"test.s:1:9: .ifdef needs a name",
+			"test.s:3:2: .if must be the last statement on its line",
+			"test.s:4:2: .if takes a condition",
+		}},
+	} {
+		_, _, errs := testAssemble(t, tc.src)
+		got := make([]string, len(errs))
+		for i, e := range errs {
+			got[i] = e.Error()
+		}
+		if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+			t.Errorf("%q: wrong errors:\ngot  %q\nwant %q", tc.src, got, tc.want)
+		}
+	}
+}
+
+func TestConditionalInclude(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.s":		"\t.if\t1\n\t.include\t\"open.inc\"\n\t.endif\n",
+		"open.inc":	"\t.if\t1\n\tnop\n",
+	})
+	_, _, errs := testAssembleFile(t, dir, "main.s")
+	var got []string
+	for _, e := range errs {
+		got = append(got, strings.ReplaceAll(e.Error(), dir + "/", ""))
+	}
+	want := "open.inc:1:2: missing .endif for .if\n\tincluded from main.s:2:2"
+	if strings.Join(got, "\n") != want {
+		t.Errorf("wrong errors:\ngot  %q\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,127 @@
+// 19 october 2026
+package asm
+
+import (
+	"strings"
+
+	"github.com/andlabs/a68/ast"
+	"github.com/andlabs/a68/core"
+)
+
+func (a *Assembler) directive(s *ast.DirectiveStmt) {
+	switch name := strings.ToLower(s.Name); name {
+	case ".dc.b", ".dc.w", ".dc.l":
+		a.dc(s, name[len(name) - 1])
+	case ".ds.b", ".ds.w", ".ds.l":
+		a.ds(s, name[len(name) - 1])
+	case ".code", ".data", ".bss":
+		a.switchSection(s, name[1:], nil)
+	case ".section":
+		a.section(s)
+	case ".org":
+		a.org(s)
+	case ".include":
+		a.include(s)
+	case ".once":
+		a.includeOnce(s)
+	case ".incbin":
+		a.incbin(s)
+	case ".if", ".elseif", ".else", ".endif", ".ifdef", ".ifndef":
+		a.condDirective(s, name)
+	default:
+		a.errorf(s.NamePos, "%s cannot be used here", s.Name)
+	}
+}
+
+// dataSizes gives the number of bytes and FixupKind of each size suffix of .dc and .ds.
+var dataSizes = map[byte]struct {
+	n		int
+	kind		core.FixupKind
+}{
+	'b':		{1, core.FixupByte},
+	'w':		{2, core.FixupWord},
+	'l':		{4, core.FixupLong},
+}
+
+func (a *Assembler) checkAligned(s *ast.DirectiveStmt, size byte) {
+	if size != 'b' && a.sec.pc & 1 != 0 {
+		a.errorf(s.NamePos, "%s at odd address $%X", s.Name, a.sec.pc)
+	}
+}
+
+// dc assembles .dc.b, .dc.w, and .dc.l, which store each of their arguments; .dc.b also takes strings.
+func (a *Assembler) dc(s *ast.DirectiveStmt, size byte) {
+	if !a.checkData(s.NamePos) {
+		return
+	}
+	a.checkAligned(s, size)
+	ds := dataSizes[size]
+	addr := a.sec.pc
+	var b []byte
+	var fixups []core.Fixup
+	for _, arg := range s.Args {
+		switch arg := arg.(type) {
+		case *ast.BadArg:
+			// already reported by the parser
+		case *ast.StringLit:
+			if size != 'b' {
+				a.errorf(arg.Pos(), "strings can only be used with .dc.b")
+				continue
+			}
+			b = append(b, arg.Value...)
+		case *ast.Expr:
+			off := len(b)
+			b = append(b, make([]byte, ds.n)...)
+			r := a.resolve(arg.X)
+			switch r.Status {
+			case core.EvalResolved:
+				if err := ds.kind.Put(b[off:], r.Value, 0); err != nil {
+					a.errs.ReportError(arg.Pos(), err)
+				}
+			case core.EvalPending:
+				fixups = append(fixups, core.Fixup{
+					Offset:	off,
+					Kind:	ds.kind,
+					X:		arg.X,
+				})
+			}
+		}
+	}
+	sp := a.emit(s.NamePos, b)
+	a.addFixups(sp, addr, fixups)
+	a.list(s.NamePos, addr, sp)
+}
+
+// ds assembles .ds.b, .ds.w, and .ds.l, which reserve space for the given number of bytes, words, or longs.
+func (a *Assembler) ds(s *ast.DirectiveStmt, size byte) {
+	if len(s.Args) != 1 {
+		a.errorf(s.NamePos, "%s takes 1 argument, not %d", s.Name, len(s.Args))
+		return
+	}
+	x, ok := s.Args[0].(*ast.Expr)
+	if !ok {
+		if _, bad := s.Args[0].(*ast.BadArg); !bad {
+			a.errorf(s.Args[0].Pos(), "%s needs a count", s.Name)
+		}
+		return
+	}
+	a.checkAligned(s, size)
+	n, ok := a.evalNow(x.X)
+	if !ok {
+		return
+	}
+	if int64(n) < 0 || n > 0xFFFFFF {
+		a.errorf(x.Pos(), "invalid count %d for %s", int64(n), s.Name)
+		return
+	}
+	addr := a.sec.pc
+	n *= uint64(dataSizes[size].n)
+	if a.sec.bss {
+		// bss sections only reserve the space
+		a.reserve(s.NamePos, uint32(n))
+		a.list(s.NamePos, addr, span{})
+		return
+	}
+	sp := a.emit(s.NamePos, make([]byte, int(n)))
+	a.list(s.NamePos, addr, sp)
+}
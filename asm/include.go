@@ -0,0 +1,165 @@
+// 19 october 2026
+package asm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/andlabs/a68/ast"
+	"github.com/andlabs/a68/token"
+)
+
+// File is a source file that was assembled, or the expansion of a macro.
+// The positions in an expansion are reported as the lines of the macro definition they came from; use File.PositionFor with adjusted false for the lines of the expansion itself.
+type File struct {
+	File		*token.File
+	Data		[]byte		// as UTF-8
+	Expanded	token.Pos		// of the invocation, if this is the expansion of a macro; NoPos otherwise
+}
+
+// Files returns every source file that was assembled, including the ones read by .include and the expansions of macros, in the order they were read.
+func (a *Assembler) Files() []File {
+	return a.files
+}
+
+// fileKey identifies a file for include cycles and .once, no matter what path it was reached by.
+func fileKey(filename string) string {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return filepath.Clean(filename)
+	}
+	return abs
+}
+
+// findFile returns the path of the file that name, given in a directive at pos, refers to.
+// A relative name is looked for in the directory of the file with the directive, and then in each of IncludePaths.
+func (a *Assembler) findFile(pos token.Pos, name string) (string, error) {
+	if filepath.IsAbs(name) {
+		_, err := os.Stat(name)
+		return name, err
+	}
+	dirs := append([]string{filepath.Dir(a.fset.Position(pos).Filename)}, a.IncludePaths...)
+	for _, dir := range dirs {
+		path := filepath.Join(dir, name)
+		_, err := os.Stat(path)
+		if err == nil {
+			return path, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("cannot find %s", name)
+}
+
+// filename returns the file name argument of .include or .incbin.
+func (a *Assembler) filename(s *ast.DirectiveStmt) (string, bool) {
+	if len(s.Args) == 0 {
+		a.errorf(s.NamePos, "%s needs a file name", s.Name)
+		return "", false
+	}
+	lit, ok := s.Args[0].(*ast.StringLit)
+	if !ok {
+		if _, bad := s.Args[0].(*ast.BadArg); !bad {
+			a.errorf(s.Args[0].Pos(), "%s needs a file name as a string", s.Name)
+		}
+		return "", false
+	}
+	return string(lit.Value), true
+}
+
+// include assembles the statements of another source file in place of .include "file".
+func (a *Assembler) include(s *ast.DirectiveStmt) {
+	name, ok := a.filename(s)
+	if !ok {
+		return
+	}
+	if len(s.Args) != 1 {
+		a.errorf(s.Args[1].Pos(), ".include takes only a file name")
+		return
+	}
+	path, err := a.findFile(s.NamePos, name)
+	if err != nil {
+		a.errs.ReportError(s.Args[0].Pos(), err)
+		return
+	}
+	key := fileKey(path)
+	if a.once[key] {
+		return
+	}
+	for _, f := range a.including {
+		if f == key {
+			a.errorf(s.Args[0].Pos(), "%s is already being included", name)
+			return
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		a.errorf(s.Args[0].Pos(), "%v", err)
+		return
+	}
+	a.assembleSource(path, data, s.NamePos)
+}
+
+// includeOnce handles .once, which keeps the file it is in from being included again.
+func (a *Assembler) includeOnce(s *ast.DirectiveStmt) {
+	if len(s.Args) != 0 {
+		a.errorf(s.Args[0].Pos(), ".once takes no arguments")
+		return
+	}
+	a.once[a.including[len(a.including) - 1]] = true
+}
+
+// incbin stores the contents of a binary file, or part of it: .incbin "file", or .incbin "file", offset, or .incbin "file", offset, length.
+func (a *Assembler) incbin(s *ast.DirectiveStmt) {
+	name, ok := a.filename(s)
+	if !ok {
+		return
+	}
+	if len(s.Args) > 3 {
+		a.errorf(s.Args[3].Pos(), ".incbin takes a file name, an optional offset, and an optional length")
+		return
+	}
+	var vals [2]uint64
+	for i, arg := range s.Args[1:] {
+		x, ok := arg.(*ast.Expr)
+		if !ok {
+			if _, bad := arg.(*ast.BadArg); !bad {
+				a.errorf(arg.Pos(), ".incbin needs a number")
+			}
+			return
+		}
+		if vals[i], ok = a.evalNow(x.X); !ok {
+			return
+		}
+	}
+	if !a.checkData(s.NamePos) {
+		return
+	}
+	path, err := a.findFile(s.NamePos, name)
+	if err != nil {
+		a.errs.ReportError(s.Args[0].Pos(), err)
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		a.errorf(s.Args[0].Pos(), "%v", err)
+		return
+	}
+	off, n := vals[0], uint64(len(data)) - vals[0]
+	if off > uint64(len(data)) {
+		a.errorf(s.Args[1].Pos(), "offset %d is past the end of %s, which is %d bytes long", int64(off), name, len(data))
+		return
+	}
+	if len(s.Args) == 3 {
+		if vals[1] > n {
+			a.errorf(s.Args[2].Pos(), "length %d goes past the end of %s, which is %d bytes long", int64(vals[1]), name, len(data))
+			return
+		}
+		n = vals[1]
+	}
+	addr := a.sec.pc
+	sp := a.emit(s.NamePos, data[off:off + n])
+	a.list(s.NamePos, addr, sp)
+}
@@ -0,0 +1,88 @@
+// 19 october 2026
+package asm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andlabs/a68/scanner"
+	"github.com/andlabs/a68/token"
+)
+
+func writeTestFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func testAssembleFile(t *testing.T, dir string, filename string) ([]Chunk, *Assembler, scanner.ErrorList) {
+	fset := token.NewFileSet()
+	errs := scanner.NewErrorCollector(fset)
+	a := New(fset, errs)
+	a.IncludePaths = []string{filepath.Join(dir, "include")}
+	a.AssembleFile(filepath.Join(dir, filename))
+	chunks, _ := a.Finish()
+	return chunks, a, errs.Errors()
+}
+
+func TestInclude(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.s":				"\t.include\t\"regs.inc\"\n\t.include\t\"sub/code.s\"\n\t.include\t\"regs.inc\"\n\tmove.l\t(ptr)+,d0\n\t.incbin\t\"data.bin\", 1, 2\n\t.incbin\t\"data.bin\", 3\n",
+		"include/regs.inc":		"\t.once\nptr .equr a1\n",
+		"sub/code.s":			"\t.include\t\"sub.inc\"\n",
+		"sub/sub.inc":			"\tnop\n",
+		"data.bin":			"\x01\x02\x03\x04\x05",
+	})
+	chunks, a, errs := testAssembleFile(t, dir, "main.s")
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if got, want := hexChunks(chunks), "0:4E712019020304 05"; got != strings.ReplaceAll(want, " ", "") {
+		t.Errorf("wrong output: got %s, want %s", got, want)
+	}
+	var names []string
+	for _, f := range a.Files() {
+		rel, _ := filepath.Rel(dir, f.File.Name())
+		names = append(names, filepath.ToSlash(rel))
+	}
+	want := "main.s include/regs.inc sub/code.s sub/sub.inc"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("wrong files: got %s, want %s", got, want)
+	}
+}
+
+func TestIncludeErrors(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"main.s":		"\t.include\t\"loop.inc\"\n\t.include\t\"missing.inc\"\n\t.incbin\t\"data.bin\", 3, 1\n\t.incbin\t\"data.bin\", 1, 3\n\t.include\t\"outer.inc\"\n",
+		"outer.inc":	"\tnop\n\t.include\t\"bad.inc\"\n",
+		"loop.inc":	"\tnop\n\t.include\t\"loop.inc\"\n",
+		"bad.inc":		"\n\tbra\tnowhere\n\t.dc.w\t(1\n",
+		"data.bin":	"\x01\x02",
+	})
+	_, _, errs := testAssembleFile(t, dir, "main.s")
+	var got []string
+	for _, e := range errs {
+		got = append(got, strings.ReplaceAll(e.Error(), dir + string(filepath.Separator), ""))
+	}
+	want := []string{
+		"bad.inc:2:6: undefined label \"nowhere\"\n\tincluded from outer.inc:2:2\n\tincluded from main.s:5:2",
+		"bad.inc:3:10: expected ')', found newline\n\tincluded from outer.inc:2:2\n\tincluded from main.s:5:2",
+		"loop.inc:2:11: loop.inc is already being included\n\tincluded from main.s:1:2",
+		"main.s:2:11: cannot find missing.inc",
+		"main.s:3:22: offset 3 is past the end of data.bin, which is 2 bytes long",
+		"main.s:4:25: length 3 goes past the end of data.bin, which is 2 bytes long",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("wrong errors:\ngot  %q\nwant %q", got, want)
+	}
+}
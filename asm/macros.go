@@ -0,0 +1,234 @@
+// 19 october 2026
+package asm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/andlabs/a68/ast"
+	"github.com/andlabs/a68/parser"
+	"github.com/andlabs/a68/scanner"
+	"github.com/andlabs/a68/token"
+)
+
+// Macros are defined with
+//
+//	.macro name param, param=default, rest...
+//	...
+//	.endm
+//
+// and invoked like instructions, as name arg, arg, ..., or name.size arg, arg, ....
+// Each expansion is the body with \param replaced by the source of the argument for that parameter, or by its default if the argument was left out.
+// The last parameter can be written rest... to take the remaining arguments, separated by commas.
+// \size is replaced by the size suffix of the invocation without its . (so push.w gives w), or nothing if it has none, and \narg by the number of arguments given.
+// A \ followed by anything else is left alone, so escapes in strings still work, as long as no parameter has the same name.
+// Local labels defined in the body, such as @loop:, are renamed in each expansion, to @loop.1, @loop.2, and so on, so that a macro can be used more than once in the same scope.
+
+// maxExpansionDepth is how deeply macro invocations can nest, so that a macro that invokes itself forever is an error instead of a hang.
+const maxExpansionDepth = 100
+
+// reservedParams are the names that every macro substitutes, and that so cannot be the names of parameters.
+var reservedParams = map[string]bool{
+	"size":	true,
+	"narg":	true,
+}
+
+func isParamRune(r byte) bool {
+	return r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+}
+
+func (a *Assembler) defineMacro(s *ast.MacroStmt) {
+	if m, ok := a.macros[s.Name]; ok {
+		a.errorf(s.NamePos, "macro %s already defined at %v", s.Name, a.fset.Position(m.NamePos))
+		return
+	}
+	a.macros[s.Name] = s
+	if err := a.symbols.checkTested(s.Name, testKey(s.Name, "")); err != nil {
+		a.errs.ReportError(s.NamePos, err)
+	}
+	seen := make(map[string]bool)
+	for i, prm := range s.Params {
+		valid := prm.Name != ""
+		for j := 0; j < len(prm.Name); j++ {
+			valid = valid && isParamRune(prm.Name[j])
+		}
+		switch {
+		case !valid:
+			a.errorf(prm.NamePos, "invalid parameter name %s; parameter names can only have letters, digits, and _", prm.Name)
+		case reservedParams[prm.Name]:
+			a.errorf(prm.NamePos, "\\%s is reserved, so %s cannot be the name of a parameter", prm.Name, prm.Name)
+		case seen[prm.Name]:
+			a.errorf(prm.NamePos, "parameter %s repeated", prm.Name)
+		case prm.Variadic && i != len(s.Params) - 1:
+			a.errorf(prm.NamePos, "only the last parameter can take the rest of the arguments")
+		}
+		seen[prm.Name] = true
+	}
+}
+
+// macroArgs returns the text to substitute for each parameter of m in the invocation s, or false if the arguments do not fit.
+func (a *Assembler) macroArgs(m *ast.MacroStmt, s *ast.MacroCallStmt) (map[string]string, bool) {
+	args := map[string]string{
+		"size":	s.Size,
+		"narg":	strconv.Itoa(len(s.Args)),
+	}
+	variadic := false
+	for i, prm := range m.Params {
+		if prm.Variadic {
+			var rest []string
+			if i < len(s.Args) {
+				for _, arg := range s.Args[i:] {
+					rest = append(rest, arg.Text)
+				}
+			}
+			args[prm.Name] = strings.Join(rest, ", ")
+			variadic = true
+			break
+		}
+		switch {
+		case i < len(s.Args) && s.Args[i].Text != "":
+			args[prm.Name] = s.Args[i].Text
+		case prm.Default != nil:
+			args[prm.Name] = prm.Default.Text
+		default:
+			a.errorf(s.NamePos, "missing argument %s for macro %s", prm.Name, m.Name)
+			return nil, false
+		}
+	}
+	if !variadic && len(s.Args) > len(m.Params) {
+		a.errorf(s.Args[len(m.Params)].Pos(), "macro %s takes at most %d arguments, not %d", m.Name, len(m.Params), len(s.Args))
+		return nil, false
+	}
+	return args, true
+}
+
+// expand assembles the expansion of the macro invoked by s.
+func (a *Assembler) expand(s *ast.MacroCallStmt) {
+	m, ok := a.macros[s.Name]
+	if !ok {
+		// cannot happen; the parser only recognizes macros whose definitions it has parsed
+		return
+	}
+	if len(a.expanding) >= maxExpansionDepth {
+		a.errorf(s.NamePos, "macros nested more than %d deep (does %s invoke itself forever?)", maxExpansionDepth, s.Name)
+		return
+	}
+	args, ok := a.macroArgs(m, s)
+	if !ok {
+		return
+	}
+	a.expansions++
+	fold := a.Mode & scanner.FoldSymbols != 0
+	lines := strings.SplitAfter(uniqueLocals(m.Body, a.expansions, fold), "\n")
+	var src strings.Builder
+	starts := make([]int, len(lines))
+	for i, line := range lines {
+		starts[i] = src.Len()
+		src.WriteString(substitute(line, args))
+	}
+
+	a.expanding = append(a.expanding, s.NamePos)
+	defer func() {
+		a.expanding = a.expanding[:len(a.expanding) - 1]
+	}()
+	a.list(s.NamePos, a.sec.pc, span{})
+	p := parser.NewParser(a.fset, s.Name, []byte(src.String()), a.Mode, a.aliases, a.macroNames)
+	// positions in the expansion are reported as the lines of the definition they came from, and the backtrace says where it was invoked
+	def := a.fset.Position(m.BodyPos)
+	for i, off := range starts {
+		p.File().AddLineColumnInfo(off, def.Filename, def.Line + i, 1)
+	}
+	a.fset.SetOrigin(p.File(), token.Origin{
+		Kind:	token.Expanded,
+		Pos:		s.NamePos,
+		Name:	s.Name,
+	})
+	a.files = append(a.files, File{
+		File:		p.File(),
+		Data:		[]byte(src.String()),
+		Expanded:	s.NamePos,
+	})
+	a.run(p)
+}
+
+// substitute replaces each \name in body with args[name].
+func substitute(body string, args map[string]string) string {
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(body, '\\')
+		if i < 0 {
+			break
+		}
+		b.WriteString(body[:i])
+		body = body[i + 1:]
+		n := 0
+		for n < len(body) && isParamRune(body[n]) {
+			n++
+		}
+		if arg, ok := args[body[:n]]; ok && n != 0 {
+			b.WriteString(arg)
+			body = body[n:]
+			continue
+		}
+		b.WriteByte('\\')
+	}
+	b.WriteString(body)
+	return b.String()
+}
+
+// localRefs calls f with the offsets of the name of each local label in body, without any .w or .l suffix, and whether that is where the label is defined.
+func localRefs(body string, f func(start int, end int, def bool)) {
+	for i := 0; i < len(body); i++ {
+		if body[i] != '@' || (i > 0 && (isParamRune(body[i - 1]) || body[i - 1] == '\\')) {
+			continue
+		}
+		end := i + 1
+		for end < len(body) && (isParamRune(body[end]) || body[end] == '.') {
+			end++
+		}
+		if end == i + 1 {
+			continue
+		}
+		def := false
+		rest := strings.TrimLeft(body[end:], " \t")
+		if len(rest) != 0 && rest[0] == ':' {
+			// @name:: and @name:+ are not definitions; see scanner.nextColon
+			def = len(rest) == 1 || !strings.ContainsRune(":+-", rune(rest[1]))
+		} else if n := end - i; n > 3 && body[end - 2] == '.' && strings.ContainsRune("wWlL", rune(body[end - 1])) {
+			end -= 2
+		}
+		f(i, end, def)
+		i = end - 1
+	}
+}
+
+// uniqueLocals renames every local label defined in body to name.n, along with every reference to it in body.
+func uniqueLocals(body string, n int, fold bool) string {
+	key := func(name string) string {
+		if fold {
+			return strings.ToLower(name)
+		}
+		return name
+	}
+	defined := make(map[string]bool)
+	localRefs(body, func(start int, end int, def bool) {
+		if def {
+			defined[key(body[start:end])] = true
+		}
+	})
+	if len(defined) == 0 {
+		return body
+	}
+	var b strings.Builder
+	last := 0
+	localRefs(body, func(start int, end int, def bool) {
+		if defined[key(body[start:end])] {
+			b.WriteString(body[last:end])
+			fmt.Fprintf(&b, ".%d", n)
+			last = end
+		}
+	})
+	b.WriteString(body[last:])
+	return b.String()
+}
@@ -0,0 +1,79 @@
+// 19 october 2026
+package asm
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMacros(t *testing.T) {
+	for _, tc := range []struct {
+		src		string
+		want	string
+	}{
+		{"\t.macro\tpush regs\n\tmovem.l\t\\regs,-(sp)\n\t.endm\n\tpush\td0-d1/a0\n", "0:48E7C080"},
+		{"\t.macro\tclr2 a, b=d1\n\tclr.\\size\t\\a\n\tclr.\\size\t\\b\n\t.endm\n\tclr2.w\td0\n\tclr2.b\td2, d3\n", "0:4240424142024203"},
+		{"\t.macro\tbytes first, rest...\n\t.dc.b\t\\narg, \\first, \\rest\n\t.endm\n\tbytes\t1, 2, 3\n\tbytes\t4, 5\n", "0:03010203020405"},
+		{"\t.macro\tbytes a=1, b=2\n\t.dc.b\t\\a, \\b\n\t.endm\n\tbytes\t, 3\n", "0:0103"},
+		{"\t.macro\twait n\n\tmove.w\t#\\n,d0\n@loop:\tdbf\td0,@loop\n\t.endm\nstart:\twait\t3\n\twait\t4\n", "0:303C000351C8FFFE303C000451C8FFFE"},
+		{"\t.macro\tinner x\n\t.dc.b\t\\x\n\t.endm\n\t.macro\touter x\n\tinner\t\\x+1\n\tinner\t\\x+2\n\t.endm\n\touter\t1\n", "0:0203"},
+		{"\t.macro\tstr s\n\t.dc.b\t\"\\s\\n\", 0\n\t.endm\n\tstr\ta\n", "0:610A00"},
+		{"\t.macro\tjump to\n\tbra.s\t\\to\n\t.endm\nstart:\tjump\t@end\n\tnop\n@end:\n", "0:60024E71"},
+	} {
+		chunks, _, errs := testAssemble(t, tc.src)
+		if len(errs) != 0 {
+			t.Errorf("%q: unexpected errors: %v", tc.src, errs)
+			continue
+		}
+		if got := hexChunks(chunks); got != tc.want {
+			t.Errorf("%q: wrong output:\ngot  %s\nwant %s", tc.src, got, tc.want)
+		}
+	}
+}
+
+func TestMacroErrors(t *testing.T) {
+	for _, tc := range []struct {
+		src		string
+		want	[]string
+	}{
+		{"\t.macro\tm a, b\n\t.endm\n\tm\t1\n\tm\t1, 2, 3\n", []string{
+			"test.s:3:2: missing argument b for macro m",
+			"test.s:4:10: macro m takes at most 2 arguments, not 3",
+		}},
+		{"\t.macro\tm\n\t.endm\n\t.macro\tm\n\t.endm\n", []string{
+			"test.s:3:9: macro m already defined at test.s:1:9",
+		}},
+		{"\t.macro\tm size, a, a, rest..., b\n\t.endm\n", []string{
+			"test.s:1:11: \\size is reserved, so size cannot be the name of a parameter",
+			"test.s:1:20: parameter a repeated",
+			"test.s:1:23: only the last parameter can take the rest of the arguments",
+		}},
+		{"\t.macro\tm\n\tbra\tnowhere\n\t.endm\n\tm\n", []string{
+			"test.s:2:6: undefined label \"nowhere\"\n\tin expansion of macro m at test.s:4:2",
+		}},
+		{"\t.macro\tinner\n\tbra\tnowhere\n\t.endm\n\t.macro\touter\n\tnop\n\tinner\n\t.endm\n\touter\n", []string{
+			"test.s:2:6: undefined label \"nowhere\"\n\tin expansion of macro inner at test.s:6:2\n\tin expansion of macro outer at test.s:8:2",
+		}},
+	} {
+		_, _, errs := testAssemble(t, tc.src)
+		got := make([]string, len(errs))
+		for i, e := range errs {
+			got[i] = e.Error()
+		}
+		if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+			t.Errorf("%q: wrong errors:\ngot  %q\nwant %q", tc.src, got, tc.want)
+		}
+	}
+}
+
+func TestMacroRecursion(t *testing.T) {
+	_, _, errs := testAssemble(t, "\t.macro\tr\n\tr\n\t.endm\n\tr\n")
+	want := fmt.Sprintf("test.s:2:2: macros nested more than %d deep (does r invoke itself forever?)\n" +
+		"\tin expansion of macro r at test.s:2:2\n" +
+		"\t(and %d more times)\n" +
+		"\tin expansion of macro r at test.s:4:2", maxExpansionDepth, maxExpansionDepth - 2)
+	if len(errs) != 1 || errs[0].Error() != want {
+		t.Errorf("wrong errors:\ngot  %v\nwant %q", errs, want)
+	}
+}
@@ -0,0 +1,97 @@
+// 19 october 2026
+package asm
+
+import (
+	"github.com/andlabs/a68/ast"
+	"github.com/andlabs/a68/core"
+	"github.com/andlabs/a68/token"
+)
+
+// zero returns an expression for a missing displacement.
+func zero(pos token.Pos) *core.Expr {
+	x := core.NewExpr()
+	x.AddInt(pos, 0)
+	x.Finish()
+	return x
+}
+
+// operand converts o into the form core.Opcode encodes, returning false if o could not be converted.
+func (a *Assembler) operand(out *core.Operand, o ast.Operand) bool {
+	out.Pos = o.Pos()
+	switch o := o.(type) {
+	case *ast.BadOperand:
+		// already reported by the parser
+		return false
+	case *ast.RegOperand:
+		out.Mode = core.DataRegDirect
+		if o.Reg.IsAddr() {
+			out.Mode = core.AddrRegDirect
+		}
+		out.Reg = o.Reg.Num()
+	case *ast.SpecialRegOperand:
+		switch o.Tok {
+		case token.CCR:
+			out.Mode = core.CCRDirect
+		case token.SR:
+			out.Mode = core.SRDirect
+		case token.USP:
+			out.Mode = core.USPDirect
+		}
+	case *ast.ImmOperand:
+		out.Mode = core.Immediate
+		out.Value = o.Value.X
+	case *ast.AbsOperand:
+		out.Value = o.Addr.X
+		switch o.Size {
+		case token.DOT_W:
+			out.Mode = core.AbsShort
+		case token.DOT_L:
+			out.Mode = core.AbsLong
+		default:
+			// without a size, use the short form if the address is already known to fit
+			out.Mode = core.AbsLong
+			r := a.resolve(o.Addr.X)
+			if r.Status == core.EvalResolved && core.FitsAbsShort(r.Value) {
+				out.Mode = core.AbsShort
+			}
+		}
+	case *ast.IndirectOperand:
+		out.Reg = o.Base.Num()
+		if o.Disp != nil {
+			out.Value = o.Disp.X
+		}
+		if o.PC && out.Value == nil {
+			// a pc-relative displacement is written as the address it leads to
+			a.errorf(o.Lparen, "(pc) needs a target address")
+			return false
+		}
+		switch {
+		case o.PreDec:
+			out.Mode = core.AddrRegPreDec
+		case o.PostInc:
+			out.Mode = core.AddrRegPostInc
+		case o.Index != nil:
+			out.Mode = core.AddrRegIndex
+			if o.PC {
+				out.Mode = core.PCIndex
+			}
+			out.Index = int(o.Index.Reg)
+			out.IndexLong = o.Index.Long
+			if out.Value == nil {
+				out.Value = zero(o.Lparen)
+			}
+		case o.PC:
+			out.Mode = core.PCDisp
+		case out.Value != nil:
+			out.Mode = core.AddrRegDisp
+		default:
+			out.Mode = core.AddrRegIndirect
+		}
+	case *ast.RegListOperand:
+		out.Mode = core.RegList
+		out.Regs = o.Regs
+	default:
+		panic("unknown operand type")
+	}
+	return true
+}
@@ -0,0 +1,200 @@
+// 19 october 2026
+package asm
+
+import (
+	"sort"
+
+	"github.com/andlabs/a68/ast"
+	"github.com/andlabs/a68/token"
+)
+
+// section is a part of the output with its own location counter.
+// Since the size of a section is not known until the end, sections are not placed after one another; each needs an .org to say where it goes, except for one section, which can start at $0 without one.
+type section struct {
+	name	string
+	bss		bool		// space is only reserved, not stored, as for variables in RAM
+	pc		uint32
+	placed	bool		// whether the section has an address, from .org or from starting at $0
+	noOrg	bool		// whether the section was reported for needing an .org, so that its overlaps are not reported as well
+	chunk	int		// the index of the chunk the section last stored to, or -1 if none
+	reserved	int		// the index of the extent the section last reserved, or -1 if none
+	pos		token.Pos	// where it was first used with .section; NoPos for the standard sections
+}
+
+// extent is a range of addresses that a section uses, for checkOverlaps.
+type extent struct {
+	sec		*section
+	addr		uint32
+	n		uint32
+	pos		token.Pos	// of the first statement in the extent
+}
+
+// standardSections are the sections that exist from the start; code is current at the start.
+// Each has a directive of the same name to switch to it, such as .data.
+var standardSections = []struct {
+	name	string
+	bss		bool
+}{
+	{"code", false},
+	{"data", false},
+	{"bss", true},
+}
+
+// place makes sure the current section has an address before the statement at pos stores, reserves, or labels anything in it.
+// The first section to need one without an .org starts at $0; any other is an error.
+func (a *Assembler) place(pos token.Pos) {
+	sec := a.sec
+	if sec.placed {
+		return
+	}
+	sec.placed = true
+	if a.atZero == nil {
+		a.atZero = sec
+		return
+	}
+	sec.noOrg = true
+	a.errorf(pos, "section %s needs an .org; only one section can start at $0 without one, and section %s already does", sec.name, a.atZero.name)
+}
+
+// reserve reserves n bytes at the location counter of the current section, which is a bss section, for the statement at pos.
+func (a *Assembler) reserve(pos token.Pos, n uint32) {
+	a.place(pos)
+	sec := a.sec
+	if sec.reserved < 0 || a.reserved[sec.reserved].addr + a.reserved[sec.reserved].n != sec.pc {
+		sec.reserved = len(a.reserved)
+		a.reserved = append(a.reserved, extent{
+			sec:		sec,
+			addr:	sec.pc,
+			pos:		pos,
+		})
+	}
+	a.reserved[sec.reserved].n += n
+	sec.pc += n
+}
+
+// checkData reports an error if the current section is a bss section, which cannot have instructions or data.
+func (a *Assembler) checkData(pos token.Pos) bool {
+	if a.sec.bss {
+		a.errorf(pos, "cannot store instructions or data in bss section %s", a.sec.name)
+		return false
+	}
+	return true
+}
+
+// switchSection makes the named section current, creating it if it does not exist yet.
+// bss is nil if the directive does not say whether the section is a bss section.
+func (a *Assembler) switchSection(s *ast.DirectiveStmt, name string, bss *bool) {
+	sec, ok := a.sections[name]
+	if !ok {
+		sec = &section{
+			name:	name,
+			bss:		bss != nil && *bss,
+			chunk:	-1,
+			reserved:	-1,
+			pos:		s.NamePos,
+		}
+		a.sections[name] = sec
+	} else if bss != nil && *bss != sec.bss {
+		where := "a standard section"
+		if sec.pos.IsValid() {
+			where = "first used at " + a.fset.Position(sec.pos).String()
+		}
+		if sec.bss {
+			a.errorf(s.NamePos, "section %s is a bss section (%s)", name, where)
+		} else {
+			a.errorf(s.NamePos, "section %s is not a bss section (%s)", name, where)
+		}
+	}
+	a.sec = sec
+	a.list(s.NamePos, sec.pc, span{})
+}
+
+// section handles .section name, or .section name, bss.
+func (a *Assembler) section(s *ast.DirectiveStmt) {
+	if len(s.Args) == 0 || len(s.Args) > 2 {
+		a.errorf(s.NamePos, ".section takes a name and an optional bss")
+		return
+	}
+	names := make([]string, len(s.Args))
+	for i, arg := range s.Args {
+		x, ok := arg.(*ast.Expr)
+		if !ok {
+			if _, bad := arg.(*ast.BadArg); !bad {
+				a.errorf(arg.Pos(), ".section needs a name")
+			}
+			return
+		}
+		if names[i], ok = x.X.Name(); !ok {
+			a.errorf(arg.Pos(), ".section needs a name")
+			return
+		}
+	}
+	var bss *bool
+	if len(names) == 2 {
+		if names[1] != "bss" {
+			a.errorf(s.Args[1].Pos(), "unknown section flag %s", names[1])
+			return
+		}
+		t := true
+		bss = &t
+	}
+	a.switchSection(s, names[0], bss)
+}
+
+// org sets the location counter of the current section.
+func (a *Assembler) org(s *ast.DirectiveStmt) {
+	if len(s.Args) != 1 {
+		a.errorf(s.NamePos, ".org takes 1 argument, not %d", len(s.Args))
+		return
+	}
+	x, ok := s.Args[0].(*ast.Expr)
+	if !ok {
+		if _, bad := s.Args[0].(*ast.BadArg); !bad {
+			a.errorf(s.Args[0].Pos(), ".org needs an address")
+		}
+		return
+	}
+	v, ok := a.evalNow(x.X)
+	if !ok {
+		return
+	}
+	if v > 0xFFFFFFFF {
+		a.errorf(x.Pos(), "address $%X is out of range", v)
+		return
+	}
+	a.sec.pc = uint32(v)
+	a.sec.placed = true
+	a.list(s.NamePos, a.sec.pc, span{})
+}
+
+// checkOverlaps reports every chunk or reserved space in a bss section that overlaps one before it in address order.
+func (a *Assembler) checkOverlaps() {
+	extents := make([]extent, 0, len(a.chunks) + len(a.reserved))
+	for i, c := range a.chunks {
+		extents = append(extents, extent{
+			sec:		a.sections[c.Section],
+			addr:	c.Addr,
+			n:		uint32(len(c.Data)),
+			pos:		a.chunkPos[i],
+		})
+	}
+	extents = append(extents, a.reserved...)
+	sort.SliceStable(extents, func(i, j int) bool {
+		return extents[i].addr < extents[j].addr
+	})
+	// compare each extent with the one before it that reaches furthest, which overlaps it if any does
+	var prev *extent
+	for i := range extents {
+		e := &extents[i]
+		if e.n == 0 {
+			continue
+		}
+		if prev != nil && uint64(e.addr) < uint64(prev.addr) + uint64(prev.n) && !e.sec.noOrg && !prev.sec.noOrg {
+			a.errorf(e.pos, "section %s at $%X overlaps section %s from %s",
+				e.sec.name, e.addr, prev.sec.name, a.fset.Position(prev.pos))
+		}
+		if prev == nil || uint64(e.addr) + uint64(e.n) > uint64(prev.addr) + uint64(prev.n) {
+			prev = e
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// 19 october 2026
+package asm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andlabs/a68/ast"
+	"github.com/andlabs/a68/token"
+)
+
+// symbolKind is the kind of a symbol.
+type symbolKind int
+const (
+	labelSymbol symbolKind = iota	// name:
+	localSymbol				// @name:, which belongs to the label before it
+	namelessSymbol			// + or -, which can only be found from within the same label
+	equateSymbol				// name .equ value, or a -D define
+	variableSymbol			// name = value, which can be assigned again
+	regAliasSymbol			// name .equr register
+)
+
+// the article is included so errors read naturally
+var symbolKindStrings = [...]string{
+	labelSymbol:		"a label",
+	localSymbol:		"a local label",
+	namelessSymbol:	"a nameless label",
+	equateSymbol:		"an equate",
+	variableSymbol:	"a variable",
+	regAliasSymbol:	"a register alias",
+}
+
+func (k symbolKind) String() string {
+	return symbolKindStrings[k]
+}
+
+// symbol is a name defined in the source.
+type symbol struct {
+	name	string
+	kind		symbolKind
+	value	uint64			// the address of a label, or the value of an equate or variable
+	reg		ast.Register		// for a register alias
+	pos		token.Pos			// where it was defined; NoPos for -D defines
+	global	string			// for local and nameless labels, the label they belong to
+}
+
+// scope is the point in the source that a name is looked up from.
+// The README promises that nameless labels are local instead of global: like @local labels, they can only be found from between the same two global labels.
+type scope struct {
+	global	string	// the last global label; "" before the first
+	next		int		// the number of + labels defined so far
+	prev		int		// the number of - labels defined so far
+}
+
+// symbolTable holds every symbol, and finds the one that a name refers to from a given scope.
+type symbolTable struct {
+	fset		*token.FileSet
+	globals	map[string]*symbol			// everything but local and nameless labels
+	locals	map[string]map[string]*symbol	// the local labels of each global label
+	next		[]*symbol
+	prev		[]*symbol
+	tested	map[string]ifdefTest				// the names that .ifdef or .ifndef found undefined, by testKey
+
+	// scope is the scope of the statement being assembled.
+	scope	scope
+}
+
+func newSymbolTable(fset *token.FileSet) *symbolTable {
+	return &symbolTable{
+		fset:		fset,
+		globals:	make(map[string]*symbol),
+		locals:	make(map[string]map[string]*symbol),
+		tested:	make(map[string]ifdefTest),
+	}
+}
+
+// ifdefTest is an .ifdef or .ifndef that found a name undefined.
+type ifdefTest struct {
+	directive	string
+	pos			token.Pos
+}
+
+// testKey is the key in tested of name, defined in or tested from after the global label global.
+func testKey(name string, global string) string {
+	if strings.HasPrefix(name, "@") {
+		// local labels are only the same if they belong to the same label
+		return global + name
+	}
+	return name
+}
+
+// test records that the .ifdef or .ifndef at pos found name undefined, so that defining it after is an error instead of silently changing what the test meant.
+func (t *symbolTable) test(name string, directive string, pos token.Pos) {
+	key := testKey(name, t.scope.global)
+	if _, ok := t.tested[key]; !ok {
+		t.tested[key] = ifdefTest{
+			directive:	directive,
+			pos:			pos,
+		}
+	}
+}
+
+// checkTested returns an error if the name with the given testKey was found undefined by an .ifdef or .ifndef before it was defined.
+func (t *symbolTable) checkTested(name string, key string) error {
+	tst, ok := t.tested[key]
+	if !ok {
+		return nil
+	}
+	delete(t.tested, key)
+	return fmt.Errorf("%s is defined after the %s at %v that tested it", name, tst.directive, t.fset.Position(tst.pos))
+}
+
+// where says where s was defined, for errors.
+func (t *symbolTable) where(s *symbol) string {
+	if !s.pos.IsValid() {
+		return "with -D"
+	}
+	return "at " + t.fset.Position(s.pos).String()
+}
+
+// define adds s to the table at the current scope, returning an error if its name is already taken.
+// Only variables can be defined again, and only as variables.
+func (t *symbolTable) define(s *symbol) error {
+	switch s.kind {
+	case localSymbol:
+		s.global = t.scope.global
+		m := t.locals[s.global]
+		if m == nil {
+			m = make(map[string]*symbol)
+			t.locals[s.global] = m
+		}
+		if old, ok := m[s.name]; ok {
+			return fmt.Errorf("%s already defined %s", s.name, t.where(old))
+		}
+		m[s.name] = s
+		return t.checkTested(s.name, testKey(s.name, s.global))
+	case namelessSymbol:
+		s.global = t.scope.global
+		if s.name == "+" {
+			t.next = append(t.next, s)
+			t.scope.next++
+		} else {
+			t.prev = append(t.prev, s)
+			t.scope.prev++
+		}
+		return nil
+	}
+	if s.kind == labelSymbol {
+		// even if the label is a duplicate, the local labels after it belong to it
+		t.scope.global = s.name
+	}
+	if old, ok := t.globals[s.name]; ok && !(old.kind == variableSymbol && s.kind == variableSymbol) {
+		return fmt.Errorf("%s already defined as %v %s", s.name, old.kind, t.where(old))
+	}
+	t.globals[s.name] = s
+	return t.checkTested(s.name, testKey(s.name, ""))
+}
+
+// nameless returns the number of nameless labels a reference like :++ skips, and whether it refers forward.
+func nameless(name string) (n int, forward bool, ok bool) {
+	if len(name) < 2 || name[0] != ':' || (name[1] != '+' && name[1] != '-') {
+		return 0, false, false
+	}
+	return len(name) - 1, name[1] == '+', true
+}
+
+// lookup returns the symbol that name refers to from sc, or nil if there is none (yet).
+func (t *symbolTable) lookup(name string, sc scope) *symbol {
+	if n, forward, ok := nameless(name); ok {
+		var s *symbol
+		if forward {
+			if i := sc.next + n - 1; i < len(t.next) {
+				s = t.next[i]
+			}
+		} else {
+			if i := sc.prev - n; i >= 0 {
+				s = t.prev[i]
+			}
+		}
+		if s == nil || s.global != sc.global {
+			return nil
+		}
+		return s
+	}
+	if strings.HasPrefix(name, "@") {
+		return t.locals[sc.global][name]
+	}
+	return t.globals[name]
+}
+
+// pending returns whether name, which does not refer to anything from sc yet, may still be defined later.
+func (t *symbolTable) pending(name string, sc scope) bool {
+	if n, forward, ok := nameless(name); ok {
+		// a + label that has been defined already, but belongs to a later label, is out of reach
+		return forward && sc.next + n - 1 >= len(t.next)
+	}
+	return t.lookup(name, sc) == nil
+}
@@ -0,0 +1,243 @@
+// 19 october 2026
+
+// Package ast declares the types used to represent the statements of a68 source.
+package ast
+
+import (
+	"strings"
+
+	"github.com/andlabs/a68/core"
+	"github.com/andlabs/a68/token"
+)
+
+// Node is implemented by every node in the tree.
+type Node interface {
+	Pos() token.Pos		// the first character of the node
+	End() token.Pos		// the first character after the node
+}
+
+// Stmt is implemented by every statement node.
+type Stmt interface {
+	Node
+	stmtNode()
+}
+
+// Operand is implemented by every instruction operand node.
+type Operand interface {
+	Node
+	operandNode()
+}
+
+// Arg is implemented by every directive argument node.
+type Arg interface {
+	Node
+	argNode()
+}
+
+// File is a parsed source file.
+type File struct {
+	Name	string
+	Stmts	[]Stmt
+}
+
+// Expressions.
+
+// Expr is an expression; the expression itself is stored in its object file form.
+// Expr is both an Arg and, as an absolute address or a plain value, part of several Operands.
+type Expr struct {
+	From		token.Pos
+	To		token.Pos
+	X		*core.Expr
+}
+
+func (x *Expr) Pos() token.Pos { return x.From }
+func (x *Expr) End() token.Pos { return x.To }
+func (*Expr) argNode() {}
+
+// StringLit is a string literal directive argument.
+type StringLit struct {
+	ValuePos	token.Pos
+	Lit		string		// as written, including quotes
+	Value	[]byte		// after processing escape sequences
+}
+
+func (x *StringLit) Pos() token.Pos { return x.ValuePos }
+func (x *StringLit) End() token.Pos { return x.ValuePos + token.Pos(len(x.Lit)) }
+func (*StringLit) argNode() {}
+
+// BadArg is a directive argument with a syntax error.
+type BadArg struct {
+	From		token.Pos
+	To		token.Pos
+}
+
+func (x *BadArg) Pos() token.Pos { return x.From }
+func (x *BadArg) End() token.Pos { return x.To }
+func (*BadArg) argNode() {}
+
+// Statements.
+
+// BadStmt is a statement with a syntax error; it extends to the end of the statement.
+type BadStmt struct {
+	From		token.Pos
+	To		token.Pos
+}
+
+func (s *BadStmt) Pos() token.Pos { return s.From }
+func (s *BadStmt) End() token.Pos { return s.To }
+
+// LabelKind says what sort of label a LabelStmt defines.
+type LabelKind int
+const (
+	GlobalLabel LabelKind = iota		// name:
+	LocalLabel					// @name:
+	NextLabel					// + (found by :+)
+	PrevLabel					// - (found by :-)
+)
+
+// LabelStmt defines a label at the current position.
+// A label shares its line with the statement after it, but it is a statement of its own.
+type LabelStmt struct {
+	LabelPos	token.Pos
+	Kind		LabelKind
+	Name	string		// including the @ of a LocalLabel; "+" or "-" for a nameless label
+	Colon	token.Pos		// NoPos for nameless labels
+}
+
+func (s *LabelStmt) Pos() token.Pos { return s.LabelPos }
+func (s *LabelStmt) End() token.Pos {
+	if s.Colon.IsValid() {
+		return s.Colon + 1
+	}
+	return s.LabelPos + token.Pos(len(s.Name))
+}
+
+// InstrStmt is an instruction.
+type InstrStmt struct {
+	NamePos	token.Pos
+	Lit		string		// the mnemonic as written, such as move.l
+	Name	string		// the mnemonic without its size, such as move
+	Size		string		// b, w, l, s, or "" if there is no size suffix
+	Operands	[]Operand
+}
+
+func (s *InstrStmt) Pos() token.Pos { return s.NamePos }
+func (s *InstrStmt) End() token.Pos {
+	if len(s.Operands) != 0 {
+		return s.Operands[len(s.Operands) - 1].End()
+	}
+	return s.NamePos + token.Pos(len(s.Lit))
+}
+
+// SplitSize splits a mnemonic like move.l into its name and size suffix.
+func SplitSize(lit string) (name string, size string) {
+	i := strings.LastIndexByte(lit, '.')
+	if i <= 0 {
+		return lit, ""
+	}
+	switch strings.ToLower(lit[i + 1:]) {
+	case "b", "w", "l", "s":
+		return lit[:i], lit[i + 1:]
+	}
+	return lit, ""
+}
+
+// DirectiveStmt is a directive, such as .dc.w 1,2,3.
+type DirectiveStmt struct {
+	NamePos	token.Pos
+	Name	string		// including the leading .
+	Args		[]Arg
+}
+
+func (s *DirectiveStmt) Pos() token.Pos { return s.NamePos }
+func (s *DirectiveStmt) End() token.Pos {
+	if len(s.Args) != 0 {
+		return s.Args[len(s.Args) - 1].End()
+	}
+	return s.NamePos + token.Pos(len(s.Name))
+}
+
+// AssignStmt gives a name a value: name = value for a variable, which can be assigned again, or name .equ value for an equate, which cannot.
+type AssignStmt struct {
+	NamePos	token.Pos
+	Name	string
+	OpPos	token.Pos
+	Equ		bool		// .equ instead of =
+	Value	*Expr
+}
+
+func (s *AssignStmt) Pos() token.Pos { return s.NamePos }
+func (s *AssignStmt) End() token.Pos { return s.Value.End() }
+
+// RegAliasStmt gives a register another name: name .equr register.
+// Once defined, the parser treats the name as that register.
+type RegAliasStmt struct {
+	NamePos	token.Pos
+	Name	string
+	OpPos	token.Pos
+	RegPos	token.Pos
+	RegLit	string
+	Reg		Register
+}
+
+func (s *RegAliasStmt) Pos() token.Pos { return s.NamePos }
+func (s *RegAliasStmt) End() token.Pos { return s.RegPos + token.Pos(len(s.RegLit)) }
+
+// MacroStmt defines a macro: everything from .macro name params to .endm.
+// The body is kept as source, since it is only parsed once the parameters are substituted into each expansion.
+type MacroStmt struct {
+	MacroPos	token.Pos		// of .macro
+	NamePos	token.Pos
+	Name	string
+	Params	[]*MacroParam
+	BodyPos	token.Pos		// the start of the line after .macro
+	Body		string		// the lines between .macro and .endm, line endings included
+	EndPos	token.Pos		// of .endm
+}
+
+func (s *MacroStmt) Pos() token.Pos { return s.MacroPos }
+func (s *MacroStmt) End() token.Pos { return s.EndPos + token.Pos(len(".endm")) }
+
+// MacroParam is a parameter of a macro: name, name=default, or, for the last parameter only, name... to take the rest of the arguments.
+type MacroParam struct {
+	NamePos	token.Pos
+	Name	string		// without the ...
+	Default	*MacroArg		// nil if the parameter has no default, in which case it must be given an argument
+	Variadic	bool
+}
+
+// MacroArg is an argument of a macro invocation, or the default value of a parameter, as written.
+type MacroArg struct {
+	From	token.Pos
+	To		token.Pos
+	Text		string		// empty for an argument that was left out, as in mac a,,c
+}
+
+func (a *MacroArg) Pos() token.Pos { return a.From }
+func (a *MacroArg) End() token.Pos { return a.To }
+
+// MacroCallStmt is the invocation of a macro, which is written like an instruction: name.size arg, arg, ....
+type MacroCallStmt struct {
+	NamePos	token.Pos
+	Lit		string		// the name as written, such as push.w
+	Name	string		// the name of the macro
+	Size		string		// the size suffix without its ., or "" if there is none
+	Args		[]*MacroArg
+}
+
+func (s *MacroCallStmt) Pos() token.Pos { return s.NamePos }
+func (s *MacroCallStmt) End() token.Pos {
+	if len(s.Args) != 0 {
+		return s.Args[len(s.Args) - 1].End()
+	}
+	return s.NamePos + token.Pos(len(s.Lit))
+}
+
+func (*BadStmt) stmtNode() {}
+func (*LabelStmt) stmtNode() {}
+func (*InstrStmt) stmtNode() {}
+func (*DirectiveStmt) stmtNode() {}
+func (*AssignStmt) stmtNode() {}
+func (*RegAliasStmt) stmtNode() {}
+func (*MacroStmt) stmtNode() {}
+func (*MacroCallStmt) stmtNode() {}
@@ -0,0 +1,180 @@
+// 19 october 2026
+package ast
+
+import (
+	"fmt"
+
+	"github.com/andlabs/a68/token"
+)
+
+// Register is a data or address register.
+type Register uint8
+const (
+	D0 Register = iota
+	D1
+	D2
+	D3
+	D4
+	D5
+	D6
+	D7
+	A0
+	A1
+	A2
+	A3
+	A4
+	A5
+	A6
+	A7
+	nRegisters
+)
+
+// SP is the stack pointer, which is another name for A7.
+const SP = A7
+
+// IsAddr returns whether r is an address register.
+func (r Register) IsAddr() bool {
+	return r >= A0 && r < nRegisters
+}
+
+// Num returns the number of r within its kind; for instance, 3 for both D3 and A3.
+func (r Register) Num() int {
+	return int(r) & 7
+}
+
+func (r Register) String() string {
+	if r >= nRegisters {
+		return fmt.Sprintf("Register(%d)", uint8(r))
+	}
+	if r.IsAddr() {
+		return fmt.Sprintf("a%d", r.Num())
+	}
+	return fmt.Sprintf("d%d", r.Num())
+}
+
+// ParseRegister returns the register named by lit, which is the literal of a DATAREG, ADDRREG, DATAREG_W, ADDRREG_W, DATAREG_L, or ADDRREG_L token.
+// The size suffix, if any, is ignored.
+func ParseRegister(lit string) (r Register, ok bool) {
+	if len(lit) >= 2 && (lit[:2] == "sp" || lit[:2] == "SP") {
+		return SP, true
+	}
+	if len(lit) < 2 || lit[1] < '0' || lit[1] > '7' {
+		return 0, false
+	}
+	r = Register(lit[1] - '0')
+	switch lit[0] {
+	case 'd', 'D':
+		return r, true
+	case 'a', 'A':
+		return r + A0, true
+	}
+	return 0, false
+}
+
+// BadOperand is an operand with a syntax error.
+type BadOperand struct {
+	From		token.Pos
+	To		token.Pos
+}
+
+func (o *BadOperand) Pos() token.Pos { return o.From }
+func (o *BadOperand) End() token.Pos { return o.To }
+
+// RegOperand is a data or address register: dn or an.
+type RegOperand struct {
+	RegPos	token.Pos
+	Lit		string
+	Reg		Register
+}
+
+func (o *RegOperand) Pos() token.Pos { return o.RegPos }
+func (o *RegOperand) End() token.Pos { return o.RegPos + token.Pos(len(o.Lit)) }
+
+// SpecialRegOperand is sr, ccr, or usp.
+type SpecialRegOperand struct {
+	RegPos	token.Pos
+	Tok		token.Token		// SR, CCR, or USP
+	Lit		string
+}
+
+func (o *SpecialRegOperand) Pos() token.Pos { return o.RegPos }
+func (o *SpecialRegOperand) End() token.Pos { return o.RegPos + token.Pos(len(o.Lit)) }
+
+// ImmOperand is an immediate value: #value.
+type ImmOperand struct {
+	Pound	token.Pos
+	Value	*Expr
+}
+
+func (o *ImmOperand) Pos() token.Pos { return o.Pound }
+func (o *ImmOperand) End() token.Pos { return o.Value.End() }
+
+// AbsOperand is an absolute address, optionally with its size given: addr, addr.w, or addr.l.
+// Branch targets and other plain values are also AbsOperands.
+type AbsOperand struct {
+	Addr		*Expr
+	SizePos	token.Pos
+	Size		token.Token		// DOT_W, DOT_L, or ILLEGAL if not given
+}
+
+func (o *AbsOperand) Pos() token.Pos { return o.Addr.Pos() }
+func (o *AbsOperand) End() token.Pos {
+	if o.Size != token.ILLEGAL {
+		return o.SizePos + 2
+	}
+	return o.Addr.End()
+}
+
+// IndexReg is the index register of an indexed addressing mode, such as d0.w.
+type IndexReg struct {
+	RegPos	token.Pos
+	Lit		string
+	Reg		Register
+	Long	bool		// .l instead of .w (the default)
+}
+
+// IndirectOperand is any of the addressing modes that go through a register:
+// 	(an)
+// 	(an)+
+// 	-(an)
+// 	disp(an)
+// 	disp(an,xn.size)
+// 	disp(pc)
+// 	disp(pc,xn.size)
+type IndirectOperand struct {
+	From		token.Pos		// - of a predecrement, the start of Disp, or (
+	Lparen	token.Pos
+	Disp		*Expr		// nil if there is none
+	PC		bool			// if set, Base is not used
+	Base		Register
+	Index	*IndexReg		// nil if there is none
+	Rparen	token.Pos
+	PreDec	bool
+	PostInc	bool
+}
+
+func (o *IndirectOperand) Pos() token.Pos { return o.From }
+func (o *IndirectOperand) End() token.Pos {
+	if o.PostInc {
+		return o.Rparen + 2
+	}
+	return o.Rparen + 1
+}
+
+// RegListOperand is a list of registers for movem, such as d0-d3/a0/a2-a6.
+type RegListOperand struct {
+	From		token.Pos
+	To		token.Pos
+	Regs		uint16		// bit n is set if Register(n) is in the list
+}
+
+func (o *RegListOperand) Pos() token.Pos { return o.From }
+func (o *RegListOperand) End() token.Pos { return o.To }
+
+func (*BadOperand) operandNode() {}
+func (*RegOperand) operandNode() {}
+func (*SpecialRegOperand) operandNode() {}
+func (*ImmOperand) operandNode() {}
+func (*AbsOperand) operandNode() {}
+func (*IndirectOperand) operandNode() {}
+func (*RegListOperand) operandNode() {}
@@ -0,0 +1,105 @@
+// 19 october 2026
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/andlabs/a68/parser"
+	"github.com/andlabs/a68/token"
+)
+
+// stringsFlag is a flag that can be given more than once, such as -I.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+// definesFlag collects -D name=value flags; a later -D for the same name replaces an earlier one.
+// The values are expressions, which are parsed and evaluated by values once the flags are all known.
+type definesFlag map[string]string
+
+// names returns the names of the defines in sorted order, so that neither diagnostics nor the order the defines are made in depend on the order of a map.
+func (f definesFlag) names() []string {
+	names := make([]string, 0, len(f))
+	for name := range f {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (f definesFlag) String() string {
+	s := make([]string, 0, len(f))
+	for _, name := range f.names() {
+		s = append(s, name + "=" + f[name])
+	}
+	return strings.Join(s, " ")
+}
+
+func (f definesFlag) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok {
+		value = "1"
+	}
+	if name == "" {
+		return fmt.Errorf("missing name in %q", s)
+	}
+	// -D can come before -fold, so names that are keywords in any case are rejected
+	if t, _ := token.LookupFold(name); t != token.IDENT || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("cannot define keyword %q", name)
+	}
+	f[name] = value
+	return nil
+}
+
+// definesEvalHandler evaluates the value of a define; defines cannot refer to symbols, or to each other.
+type definesEvalHandler struct {
+	err	error
+}
+
+func (h *definesEvalHandler) LookupName(name string) (uint64, bool) {
+	return 0, false
+}
+
+func (h *definesEvalHandler) ReportError(pos token.Pos, err error) {
+	if h.err == nil {
+		h.err = err
+	}
+}
+
+// define is the name and value of a -D flag.
+type define struct {
+	name	string
+	value	uint64
+}
+
+// values returns the value of each define, in order by name.
+func (f definesFlag) values(fset *token.FileSet) ([]define, error) {
+	v := make([]define, 0, len(f))
+	for _, name := range f.names() {
+		value := f[name]
+		x, err := parser.ParseExpr(fset, "-D " + name, []byte(value), scanMode())
+		if err != nil {
+			// err already has the -D name as its position
+			return nil, err
+		}
+		h := &definesEvalHandler{}
+		n, ok := x.X.Evaluate(h)
+		if !ok {
+			return nil, fmt.Errorf("-D %s=%s: %v", name, value, h.err)
+		}
+		v = append(v, define{
+			name:	name,
+			value:	n,
+		})
+	}
+	return v, nil
+}
@@ -0,0 +1,115 @@
+// 19 october 2026
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andlabs/a68/asm"
+	"github.com/andlabs/a68/token"
+)
+
+// listing bytes are shown this many to a line; the rest go on continuation lines
+const listBytes = 8
+
+func writeListData(w *bufio.Writer, lineno string, addr uint32, data []byte, text []byte) {
+	hex := ""
+	for _, b := range data {
+		hex += fmt.Sprintf("%02X", b)
+	}
+	fmt.Fprintf(w, "%6s  %06X  %-*s  %s\n", lineno, addr, listBytes * 2, hex, text)
+}
+
+// listKey identifies a line of a file, or of a macro expansion, which can have the same name as other expansions of the same macro.
+type listKey struct {
+	file	*token.File
+	line	int
+}
+
+// fileOf returns the file that pos is in, and the line it is on in that file itself, ignoring the line mappings of macro expansions.
+func fileOf(files []asm.File, pos token.Pos) (listKey, bool) {
+	for _, s := range files {
+		if s.File.Base() <= int(pos) && int(pos) <= s.File.Base() + s.File.Size() {
+			return listKey{s.File, s.File.PositionFor(pos, false).Line}, true
+		}
+	}
+	return listKey{}, false
+}
+
+// writeListing writes each line of files along with the address and bytes that the statements on that line produced.
+// The lines of each macro expansion follow the line that invoked the macro, each marked with a + for every level of expansion and numbered by the line of the definition it came from.
+func writeListing(w io.Writer, files []asm.File, lines []asm.ListLine) error {
+	bw := bufio.NewWriter(w)
+	byLine := make(map[listKey][]asm.ListLine)
+	for _, l := range lines {
+		k, _ := fileOf(files, l.Pos)
+		byLine[k] = append(byLine[k], l)
+	}
+	expansions := make(map[listKey][]asm.File)
+	for _, s := range files {
+		if s.Expanded.IsValid() {
+			if k, ok := fileOf(files, s.Expanded); ok {
+				expansions[k] = append(expansions[k], s)
+			}
+		}
+	}
+
+	var writeFile func(s asm.File, depth int)
+	writeFile = func(s asm.File, depth int) {
+		text := s.Data
+		for n := 1; len(text) != 0; n++ {
+			start := s.File.Base() + len(s.Data) - len(text)
+			line := text
+			if i := bytes.IndexByte(text, '\n'); i >= 0 {
+				line, text = text[:i], text[i + 1:]
+			} else {
+				text = nil
+			}
+			line = bytes.TrimRight(line, "\r")
+
+			lineno := fmt.Sprint(n)
+			if depth != 0 {
+				lineno = strings.Repeat("+", depth) + fmt.Sprint(s.File.Position(token.Pos(start)).Line)
+			}
+			k := listKey{s.File, n}
+			recs := byLine[k]
+			var data []byte
+			for _, r := range recs {
+				data = append(data, r.Data...)
+			}
+			if len(data) == 0 {
+				if len(recs) == 0 {
+					fmt.Fprintf(bw, "%6s  %6s  %*s  %s\n", lineno, "", listBytes * 2, "", line)
+				} else {
+					writeListData(bw, lineno, recs[0].Addr, nil, line)
+				}
+			} else {
+				addr := recs[0].Addr
+				for len(data) != 0 {
+					m := len(data)
+					if m > listBytes {
+						m = listBytes
+					}
+					writeListData(bw, lineno, addr, data[:m], line)
+					data = data[m:]
+					addr += uint32(m)
+					lineno, line = "", nil
+				}
+			}
+			for _, e := range expansions[k] {
+				writeFile(e, depth + 1)
+			}
+		}
+	}
+	for _, s := range files {
+		if s.Expanded.IsValid() {
+			continue
+		}
+		fmt.Fprintf(bw, "%s\n", s.File.Name())
+		writeFile(s, 0)
+	}
+	return bw.Flush()
+}
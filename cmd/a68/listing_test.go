@@ -0,0 +1,49 @@
+// 19 october 2026
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/andlabs/a68/asm"
+	"github.com/andlabs/a68/scanner"
+	"github.com/andlabs/a68/token"
+)
+
+func TestWriteListingMacros(t *testing.T) {
+	fset := token.NewFileSet()
+	errs := scanner.NewErrorCollector(fset)
+	a := asm.New(fset, errs)
+	a.AssembleSource("test.s", []byte("\t.macro\tinner\n\tnop\n\t.endm\n\t.macro\touter\n\tinner\n\trts\n\t.endm\n\touter\n"))
+	_, lines := a.Finish()
+	if err := errs.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var b bytes.Buffer
+	if err := writeListing(&b, a.Files(), lines); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	blank := func(n int, text string) string {
+		return fmt.Sprintf("%6d  %6s  %16s  %s\n", n, "", "", text)
+	}
+	data := func(lineno string, addr int, hex string, text string) string {
+		return fmt.Sprintf("%6s  %06X  %-16s  %s\n", lineno, addr, hex, text)
+	}
+	want := "test.s\n" +
+		blank(1, "\t.macro\tinner") +
+		blank(2, "\tnop") +
+		blank(3, "\t.endm") +
+		blank(4, "\t.macro\touter") +
+		blank(5, "\tinner") +
+		blank(6, "\trts") +
+		blank(7, "\t.endm") +
+		data("8", 0, "", "\touter") +
+		data("+5", 0, "", "\tinner") +
+		data("++2", 0, "4E71", "\tnop") +
+		data("+6", 2, "4E75", "\trts")
+	if got := b.String(); got != want {
+		t.Errorf("wrong listing:\ngot\n%s\nwant\n%s", strings.ReplaceAll(got, "\t", "→"), strings.ReplaceAll(want, "\t", "→"))
+	}
+}
@@ -0,0 +1,155 @@
+// 19 october 2026
+
+// Command a68 assembles 68000 source files.
+//
+// Usage:
+//
+// 	a68 [flags] file.s...
+//
+// The files are assembled in order, as if they were one file, into a single output.
+// Diagnostics are printed to standard error as file:line:column: message.
+// a68 exits with status 1 if there were any, and 2 if the command line is wrong.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andlabs/a68/asm"
+	"github.com/andlabs/a68/scanner"
+	"github.com/andlabs/a68/token"
+)
+
+var (
+	includePaths	stringsFlag
+	defines		= definesFlag{}
+	outFile		= flag.String("o", "", "write output to `file` (default: the first source file with its extension replaced)")
+	format		= flag.String("f", "bin", "output `format`: bin (raw binary) or srec (Motorola S-records)")
+	fill			= flag.Uint("fill", 0xFF, "fill gaps in bin output with `byte`")
+	listFile		= flag.String("l", "", "write a listing to `file`")
+	fold			= flag.Bool("fold", false, "recognize keywords regardless of case")
+	foldAll		= flag.Bool("foldall", false, "also treat symbols case-insensitively (implies -fold)")
+	motorola		= flag.Bool("motorola", false, "accept Motorola-style integer literals such as 0FFh and @17")
+	encoding		= flag.String("encoding", "", "source file `encoding`: UTF-8, UTF-16, UTF-16LE, UTF-16BE, latin1, or windows-1252 (default UTF-8, or UTF-16 with a byte order mark)")
+	maxErrors	= flag.Int("e", 20, "stop printing diagnostics after `n` errors; 0 prints them all")
+)
+
+func init() {
+	flag.Var(&includePaths, "I", "add `dir` to the include search path; can be repeated")
+	flag.Var(defines, "D", "define `name=value` (or name, which is 1) before assembling; can be repeated")
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s [flags] file.s...\n", filepath.Base(os.Args[0]))
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+// fatalf reports an error that stops a68 outright, such as being unable to write the output.
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "a68: " + format + "\n", args...)
+	os.Exit(1)
+}
+
+func scanMode() scanner.Mode {
+	mode := scanner.Mode(0)
+	if *fold {
+		mode |= scanner.FoldKeywords
+	}
+	if *foldAll {
+		mode |= scanner.FoldSymbols
+	}
+	if *motorola {
+		mode |= scanner.MotorolaNumbers
+	}
+	return mode
+}
+
+// printErrors prints the diagnostics in errs, if any, and exits.
+func printErrors(errs *scanner.ErrorCollector) {
+	if errs.Len() == 0 {
+		return
+	}
+	errs.Limit = *maxErrors
+	scanner.PrintError(os.Stderr, errs.Err())
+	os.Exit(1)
+}
+
+func outputName(first string) string {
+	if *outFile != "" {
+		return *outFile
+	}
+	ext := ".bin"
+	if *format == "srec" {
+		ext = ".s68"
+	}
+	return strings.TrimSuffix(first, filepath.Ext(first)) + ext
+}
+
+func writeFile(name string, write func(w io.Writer) error) {
+	f, err := os.Create(name)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	err = write(f)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		os.Remove(name)
+		fatalf("writing %s: %v", name, err)
+	}
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+	}
+	if *format != "bin" && *format != "srec" {
+		fmt.Fprintf(os.Stderr, "a68: unknown output format %q\n", *format)
+		usage()
+	}
+	if *fill > 0xFF {
+		fmt.Fprintf(os.Stderr, "a68: fill byte %d out of range\n", *fill)
+		usage()
+	}
+
+	fset := token.NewFileSet()
+	errs := scanner.NewErrorCollector(fset)
+	values, err := defines.values(fset)
+	if err != nil {
+		scanner.PrintError(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	a := asm.New(fset, errs)
+	a.IncludePaths = includePaths
+	a.Mode = scanMode()
+	a.Encoding = *encoding
+	for _, d := range values {
+		a.Define(d.name, d.value)
+	}
+	for _, filename := range flag.Args() {
+		a.AssembleFile(filename)
+	}
+	chunks, lines := a.Finish()
+	printErrors(errs)
+
+	if *listFile != "" {
+		writeFile(*listFile, func(w io.Writer) error {
+			return writeListing(w, a.Files(), lines)
+		})
+	}
+	writeFile(outputName(flag.Arg(0)), func(w io.Writer) error {
+		if *format == "srec" {
+			return writeSRecords(w, chunks)
+		}
+		return writeBinary(w, chunks, byte(*fill))
+	})
+}
@@ -0,0 +1,78 @@
+// 19 october 2026
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/andlabs/a68/asm"
+)
+
+func sortChunks(chunks []asm.Chunk) []asm.Chunk {
+	sorted := make([]asm.Chunk, len(chunks))
+	copy(sorted, chunks)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Addr < sorted[j].Addr
+	})
+	return sorted
+}
+
+// writeBinary writes chunks as a raw binary image starting at the lowest address, with the gaps between them filled with fill.
+// Chunks that overlap are an error.
+func writeBinary(w io.Writer, chunks []asm.Chunk, fill byte) error {
+	bw := bufio.NewWriter(w)
+	sorted := sortChunks(chunks)
+	var next uint32
+	for i, c := range sorted {
+		if i == 0 {
+			next = c.Addr
+		}
+		if c.Addr < next {
+			return fmt.Errorf("output overlaps at address $%X", c.Addr)
+		}
+		for ; next < c.Addr; next++ {
+			bw.WriteByte(fill)
+		}
+		bw.Write(c.Data)
+		next += uint32(len(c.Data))
+	}
+	return bw.Flush()
+}
+
+// S-records carry at most this many data bytes each, as most tools expect.
+const srecDataBytes = 32
+
+func writeSRecord(w *bufio.Writer, typ byte, addr uint32, addrBytes int, data []byte) {
+	n := addrBytes + len(data) + 1
+	sum := byte(n)
+	fmt.Fprintf(w, "S%c%02X", typ, n)
+	for i := addrBytes - 1; i >= 0; i-- {
+		b := byte(addr >> (8 * i))
+		sum += b
+		fmt.Fprintf(w, "%02X", b)
+	}
+	for _, b := range data {
+		sum += b
+		fmt.Fprintf(w, "%02X", b)
+	}
+	fmt.Fprintf(w, "%02X\n", ^sum)
+}
+
+// writeSRecords writes chunks as Motorola S-records: an S0 header, S2 data records (24-bit addresses, as the 68000 has), and an S8 end record.
+func writeSRecords(w io.Writer, chunks []asm.Chunk) error {
+	bw := bufio.NewWriter(w)
+	writeSRecord(bw, '0', 0, 2, []byte("a68"))
+	for _, c := range sortChunks(chunks) {
+		for off := 0; off < len(c.Data); off += srecDataBytes {
+			end := off + srecDataBytes
+			if end > len(c.Data) {
+				end = len(c.Data)
+			}
+			writeSRecord(bw, '2', c.Addr + uint32(off), 3, c.Data[off:end])
+		}
+	}
+	writeSRecord(bw, '8', 0, 3, nil)
+	return bw.Flush()
+}
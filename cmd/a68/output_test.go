@@ -0,0 +1,73 @@
+// 19 october 2026
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/andlabs/a68/asm"
+)
+
+func TestWriteBinary(t *testing.T) {
+	var b bytes.Buffer
+	err := writeBinary(&b, []asm.Chunk{
+		{Addr: 0x104, Data: []byte{5, 6}},
+		{Addr: 0x100, Data: []byte{1, 2}},
+	}, 0xFF)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []byte{1, 2, 0xFF, 0xFF, 5, 6}
+	if !bytes.Equal(b.Bytes(), want) {
+		t.Errorf("wrong output: got % X, want % X", b.Bytes(), want)
+	}
+
+	err = writeBinary(&b, []asm.Chunk{
+		{Addr: 0x100, Data: []byte{1, 2}},
+		{Addr: 0x101, Data: []byte{3}},
+	}, 0xFF)
+	if err == nil {
+		t.Errorf("no error for overlapping chunks")
+	}
+}
+
+func TestWriteSRecords(t *testing.T) {
+	var b bytes.Buffer
+	data := make([]byte, srecDataBytes + 1)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	err := writeSRecords(&b, []asm.Chunk{{Addr: 0x12345, Data: data}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	want := []string{"S0", "S2", "S2", "S8"}
+	if len(lines) != len(want) {
+		t.Fatalf("wrong number of records: got %d, want %d\n%s", len(lines), len(want), b.String())
+	}
+	for i, l := range lines {
+		if l[:2] != want[i] {
+			t.Errorf("record %d is %s, want %s", i, l[:2], want[i])
+		}
+		rec, err := hex.DecodeString(l[2:])
+		if err != nil {
+			t.Fatalf("record %d not hexadecimal: %v", i, err)
+		}
+		if int(rec[0]) != len(rec) - 1 {
+			t.Errorf("record %d has wrong count: got %d, want %d", i, rec[0], len(rec) - 1)
+		}
+		sum := byte(0)
+		for _, c := range rec {
+			sum += c
+		}
+		if sum != 0xFF {
+			t.Errorf("record %d has wrong checksum", i)
+		}
+	}
+	if !strings.HasPrefix(lines[2], "S205012365") {
+		t.Errorf("second data record wrong: got %s", lines[2])
+	}
+}
@@ -0,0 +1,274 @@
+// 19 october 2026
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	gotoken "go/token"
+)
+
+// Size is the size of an operation, as given by an instruction's size suffix.
+type Size int
+const (
+	SizeNone Size = iota		// no suffix
+	SizeByte				// .b
+	SizeWord				// .w
+	SizeLong				// .l
+	SizeShort				// .s, for short branches; the same as .b for them
+)
+
+var sizeStrings = [...]string{
+	SizeNone:		"",
+	SizeByte:		"b",
+	SizeWord:		"w",
+	SizeLong:		"l",
+	SizeShort:	"s",
+}
+
+func (s Size) String() string {
+	if s < 0 || int(s) >= len(sizeStrings) {
+		return fmt.Sprintf("Size(%d)", int(s))
+	}
+	return sizeStrings[s]
+}
+
+// ParseSize returns the Size for a size suffix without its dot, in either case; "" is SizeNone.
+func ParseSize(suffix string) (Size, bool) {
+	switch suffix {
+	case "":
+		return SizeNone, true
+	case "b", "B":
+		return SizeByte, true
+	case "w", "W":
+		return SizeWord, true
+	case "l", "L":
+		return SizeLong, true
+	case "s", "S":
+		return SizeShort, true
+	}
+	return SizeNone, false
+}
+
+// FixupKind says how a value is stored into encoded bytes, and what range it has to be in.
+type FixupKind int
+const (
+	FixupByte	FixupKind = iota	// a byte, signed or unsigned
+	FixupWord				// a word, signed or unsigned
+	FixupLong				// a long, signed or unsigned
+	FixupDisp8				// a signed byte displacement
+	FixupDisp16				// a signed word displacement
+	FixupAbsShort			// an address that can be sign-extended from a word
+	FixupPCRel8				// a signed byte displacement from PC, for d8(pc,xn)
+	FixupPCRel16			// a signed word displacement from PC, for d16(pc) and word branches
+	FixupBranch8			// a short branch displacement from PC, which cannot be 0
+	FixupQuick				// 1 to 8 in bits 9 to 11 of a word, for addq, subq, and shifts
+	FixupMoveq				// a signed byte in the second byte of a word, for moveq
+	FixupVector				// 0 to 15 in the low four bits of the second byte of a word, for trap
+)
+
+// Put stores v into b as k says, returning an error if v is out of range.
+// pc is the value of the program counter that PC-relative kinds are relative to.
+func (k FixupKind) Put(b []byte, v uint64, pc uint32) error {
+	s := int64(v)
+	switch k {
+	case FixupByte:
+		if s < -0x80 || s > 0xFF {
+			return fmt.Errorf("value %d does not fit in a byte", s)
+		}
+		b[0] = byte(v)
+	case FixupWord:
+		if s < -0x8000 || s > 0xFFFF {
+			return fmt.Errorf("value %d does not fit in a word", s)
+		}
+		binary.BigEndian.PutUint16(b, uint16(v))
+	case FixupLong:
+		if s < -0x80000000 || s > 0xFFFFFFFF {
+			return fmt.Errorf("value %d does not fit in a long", s)
+		}
+		binary.BigEndian.PutUint32(b, uint32(v))
+	case FixupDisp8:
+		if s < -0x80 || s > 0x7F {
+			return fmt.Errorf("displacement %d does not fit in a signed byte", s)
+		}
+		b[0] = byte(v)
+	case FixupDisp16:
+		if s < -0x8000 || s > 0x7FFF {
+			return fmt.Errorf("displacement %d does not fit in a signed word", s)
+		}
+		binary.BigEndian.PutUint16(b, uint16(v))
+	case FixupAbsShort:
+		if !FitsAbsShort(v) {
+			return fmt.Errorf("address $%X cannot be used as an absolute short address", v)
+		}
+		binary.BigEndian.PutUint16(b, uint16(v))
+	case FixupPCRel8, FixupBranch8:
+		d := int64(uint32(v) - pc)
+		d = int64(int32(d))
+		if d < -0x80 || d > 0x7F {
+			return fmt.Errorf("target $%X is out of range of a byte displacement (%d bytes away)", v, d)
+		}
+		if k == FixupBranch8 && d == 0 {
+			return fmt.Errorf("short branch cannot go to the next instruction")
+		}
+		b[0] = byte(d)
+	case FixupPCRel16:
+		d := int64(int32(uint32(v) - pc))
+		if d < -0x8000 || d > 0x7FFF {
+			return fmt.Errorf("target $%X is out of range of a word displacement (%d bytes away)", v, d)
+		}
+		binary.BigEndian.PutUint16(b, uint16(d))
+	case FixupQuick:
+		if v < 1 || v > 8 {
+			return fmt.Errorf("quick value %d is not between 1 and 8", s)
+		}
+		w := binary.BigEndian.Uint16(b) &^ (7 << 9)
+		binary.BigEndian.PutUint16(b, w | uint16(v & 7) << 9)
+	case FixupMoveq:
+		if s < -0x80 || s > 0xFF {
+			return fmt.Errorf("moveq value %d does not fit in a byte", s)
+		}
+		b[1] = byte(v)
+	case FixupVector:
+		if v > 15 {
+			return fmt.Errorf("trap vector %d is not between 0 and 15", s)
+		}
+		b[1] = b[1] &^ 0xF | byte(v)
+	default:
+		panic(fmt.Sprintf("invalid FixupKind %d", k))
+	}
+	return nil
+}
+
+// FitsAbsShort returns whether the address v can be used as an absolute short address, (v).w.
+func FitsAbsShort(v uint64) bool {
+	s := int64(v)
+	if s >= -0x8000 && s <= 0x7FFF {
+		return true
+	}
+	// addresses are 24 bits on the 68000 and 32 bits on its successors, so both kinds of sign-extended address are fine
+	v &= 0xFFFFFFFF
+	return (v >= 0xFFFF8000) || (v >= 0xFF8000 && v <= 0xFFFFFF)
+}
+
+// Fixup is a value in an encoded instruction that could not be computed yet, because it refers to names that are not yet defined.
+type Fixup struct {
+	Offset	int			// of the value in the encoded bytes
+	Kind		FixupKind
+	X		*Expr
+	PC		uint32		// for PC-relative kinds
+}
+
+// ValueError is an error in a value of an instruction, such as one out of range.
+type ValueError struct {
+	Pos		gotoken.Pos
+	Err		error
+}
+
+func (e *ValueError) Error() string {
+	return e.Err.Error()
+}
+
+// OperandError is an error in an operand of an instruction, such as an addressing mode the instruction does not allow.
+type OperandError struct {
+	N		int		// the index of the operand
+	Err		error
+}
+
+func (e *OperandError) Error() string {
+	return e.Err.Error()
+}
+
+// Encoder collects the encoding of one instruction.
+type Encoder struct {
+	// PC is the address of the instruction.
+	PC		uint32
+
+	// Eval evaluates an expression in the instruction; errors in it are reported by Eval.
+	// If the expression refers to names that are not defined yet, Eval returns EvalPending, and the Encoder records a Fixup for the value.
+	Eval		func(x *Expr) EvalResult
+
+	// Bytes and Fixups are the encoded instruction and its fixups; Reset clears them.
+	Bytes	[]byte
+	Fixups	[]Fixup
+}
+
+// Reset prepares e to encode an instruction at pc.
+func (e *Encoder) Reset(pc uint32) {
+	e.PC = pc
+	e.Bytes = e.Bytes[:0]
+	e.Fixups = e.Fixups[:0]
+}
+
+func (e *Encoder) word(w uint16) int {
+	off := len(e.Bytes)
+	e.Bytes = binary.BigEndian.AppendUint16(e.Bytes, w)
+	return off
+}
+
+func (e *Encoder) long(l uint32) int {
+	off := len(e.Bytes)
+	e.Bytes = binary.BigEndian.AppendUint32(e.Bytes, l)
+	return off
+}
+
+// put stores the already-evaluated value of x at off.
+func (e *Encoder) put(r EvalResult, x *Expr, kind FixupKind, off int, pc uint32) error {
+	switch r.Status {
+	case EvalResolved:
+		if err := kind.Put(e.Bytes[off:], r.Value, pc); err != nil {
+			return &ValueError{
+				Pos:		x.Pos(),
+				Err:		err,
+			}
+		}
+	case EvalPending:
+		e.Fixups = append(e.Fixups, Fixup{
+			Offset:	off,
+			Kind:	kind,
+			X:		x,
+			PC:		pc,
+		})
+	}
+	// EvalInvalid has already been reported; leave the zeroes there
+	return nil
+}
+
+func (e *Encoder) value(x *Expr, kind FixupKind, off int, pc uint32) error {
+	return e.put(e.Eval(x), x, kind, off, pc)
+}
+
+// ext appends the extension words of o, whose operation is of the given size.
+func (e *Encoder) ext(o *Operand, size Size) error {
+	switch o.Mode {
+	case AddrRegDisp:
+		return e.value(o.Value, FixupDisp16, e.word(0), 0)
+	case AddrRegIndex, PCIndex:
+		w := uint16(o.Index) << 12
+		if o.IndexLong {
+			w |= 1 << 11
+		}
+		off := e.word(w)
+		if o.Mode == PCIndex {
+			return e.value(o.Value, FixupPCRel8, off + 1, e.PC + uint32(off))
+		}
+		return e.value(o.Value, FixupDisp8, off + 1, 0)
+	case AbsShort:
+		return e.value(o.Value, FixupAbsShort, e.word(0), 0)
+	case AbsLong:
+		return e.value(o.Value, FixupLong, e.long(0), 0)
+	case PCDisp:
+		off := e.word(0)
+		return e.value(o.Value, FixupPCRel16, off, e.PC + uint32(off))
+	case Immediate:
+		switch size {
+		case SizeByte:
+			return e.value(o.Value, FixupByte, e.word(0) + 1, 0)
+		case SizeWord:
+			return e.value(o.Value, FixupWord, e.word(0), 0)
+		case SizeLong:
+			return e.value(o.Value, FixupLong, e.long(0), 0)
+		}
+		panic(fmt.Sprintf("invalid immediate size %v", size))
+	}
+	return nil
+}
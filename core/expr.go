@@ -6,6 +6,7 @@ import (
 	"io"
 	"encoding/binary"
 	"bytes"
+	gotoken "go/token"
 )
 
 // TODO properly mark internal errors?
@@ -101,6 +102,7 @@ type exprOp struct {
 	code		ExprOpcode
 	int		uint64		// (ExprInt, ExprName - see below comment)
 	str		string		// (ExprName) length is stored in int to simplify below code
+	pos		gotoken.Pos	// (all) only stored by encodings with ExprPositions
 }
 
 // TODO allow overriding what's returned on EOF
@@ -148,7 +150,10 @@ func readExprOp(r *trackingReader) (e exprOp, err error) {
 	}
 	e.code = ExprOpcode(b)
 	if e.code >= nExprOpcodes {
-		return e, fmt.Errorf("bad opcode 0x%X", e.code)
+		return e, &ExprOpcodeVersionError{
+			Opcode:	e.code,
+			Version:	ExprVersion1,
+		}
 	}
 	if e.code == ExprInt || e.code == ExprName {
 		e.int, err = binary.ReadUvarint(r)
@@ -211,7 +216,8 @@ func NewExpr() *Expr {
 	}
 }
 
-func (e *Expr) Add(code ExprOpcode) error {
+// Add adds the operation code, which comes from the source at pos, to e.
+func (e *Expr) Add(pos gotoken.Pos, code ExprOpcode) error {
 	if e.finished {
 		return fmt.Errorf("cannot add to finished expression")
 	}
@@ -220,22 +226,26 @@ func (e *Expr) Add(code ExprOpcode) error {
 	}
 	e.ops = append(e.ops, exprOp{
 		code:		code,
+		pos:			pos,
 	})
 	return nil
 }
 
-func (e *Expr) AddInt(n uint64) error {
+// AddInt adds the integer n, which comes from the source at pos, to e.
+func (e *Expr) AddInt(pos gotoken.Pos, n uint64) error {
 	if e.finished {
 		return fmt.Errorf("cannot add to finished expression")
 	}
 	e.ops = append(e.ops, exprOp{
 		code:		ExprInt,
 		int:			n,
+		pos:			pos,
 	})
 	return nil
 }
 
-func (e *Expr) AddName(name string) error {
+// AddName adds a reference to name, which comes from the source at pos, to e.
+func (e *Expr) AddName(pos gotoken.Pos, name string) error {
 	if e.finished {
 		return fmt.Errorf("cannot add to finished expression")
 	}
@@ -246,6 +256,7 @@ func (e *Expr) AddName(name string) error {
 		code:		ExprName,
 		int:			uint64(len(name)),
 		str:			name,
+		pos:			pos,
 	})
 	return nil
 }
@@ -286,27 +297,69 @@ func (e *Expr) Empty() bool {
 	return len(e.ops) == 0
 }
 
+// Pos returns the first valid position of the operations in e, or NoPos if there is none.
+// As expressions are stored in postfix order, this is usually the position of the leftmost operand.
+func (e *Expr) Pos() gotoken.Pos {
+	for _, op := range e.ops {
+		if op.pos.IsValid() {
+			return op.pos
+		}
+	}
+	return gotoken.NoPos
+}
+
+// Name returns the name that e consists of, if e is nothing but a single name.
+func (e *Expr) Name() (name string, ok bool) {
+	if len(e.ops) != 1 || e.ops[0].code != ExprName {
+		return "", false
+	}
+	return e.ops[0].str, true
+}
+
+// Bind returns a copy of e in which every name that lookup knows the value of is replaced by that value.
+// The assembler uses this to fix the names whose values can change, such as the location counter, before it evaluates e later.
+func (e *Expr) Bind(lookup func(name string) (val uint64, ok bool)) *Expr {
+	e2 := &Expr{
+		ops:		make([]exprOp, len(e.ops)),
+		finished:	e.finished,
+	}
+	copy(e2.ops, e.ops)
+	for i, op := range e2.ops {
+		if op.code != ExprName {
+			continue
+		}
+		if val, ok := lookup(op.str); ok {
+			e2.ops[i] = exprOp{
+				code:		ExprInt,
+				int:			val,
+				pos:			op.pos,
+			}
+		}
+	}
+	return e2
+}
+
 func (e *Expr) ReadFrom(r io.Reader) (n int64, err error) {
 	return e.readFrom(&trackingReader{r: r})
 }
 
 func (e *Expr) readFrom(r *trackingReader) (n int64, err error) {
-	e2, err := readExpr(r)
+	e2, err := readExpr(r, &ExprCodec{})
 	if err == nil {
 		*e = *e2
 	}
 	return int64(r.n), err
 }
 
-func readExpr(r *trackingReader) (e *Expr, err error) {
+func readExpr(r *trackingReader, c *ExprCodec) (e *Expr, err error) {
 	e = NewExpr()
 	n, err := binary.ReadUvarint(r)
 	if err != nil {
 		return nil, readError(err)
 	}
 	if n == 0 {
-		// Since we cannot have zero-length expressions yet, we can use whether the first byte (and thus, n) is zero to denote any future revisions to the binary expression representation.
-		return nil, fmt.Errorf("unsupported expression encoding version")
+		// Since we cannot have zero-length expressions, a zero first byte (and thus, n) introduces a versioned encoding; see exprcodec.go.
+		return c.readVersioned(r)
 	}
 	e.ops = make([]exprOp, n)
 	for i, _ := range e.ops {
@@ -344,7 +397,8 @@ func (e *Expr) WriteTo(w io.Writer) (n int64, err error) {
 
 type EvaluateHandler interface {
 	LookupName(name string) (val uint64, ok bool)
-	ReportError(err error)
+	// ReportError reports an error in the operation at pos, which is NoPos if the error applies to the whole expression or if the expression has no positions.
+	ReportError(pos gotoken.Pos, err error)
 }
 
 var (
@@ -366,43 +420,104 @@ func boolval(b bool) uint64 {
 	return 0
 }
 
+// Evaluate evaluates e, treating every name that handler cannot look up as an error.
+// This is what the linker uses, as by then every name must be known.
 func (e *Expr) Evaluate(handler EvaluateHandler) (val uint64, ok bool) {
+	r := e.evaluate(handler, nil)
+	return r.Value, r.Status == EvalResolved
+}
+
+// EvalStatus describes the outcome of Expr.Resolve.
+type EvalStatus int
+const (
+	// EvalResolved means the expression has a value.
+	EvalResolved EvalStatus = iota
+	// EvalPending means the expression depends on names that are not defined yet, but may be later.
+	// No errors have been reported for the parts of the expression that depend on those names.
+	EvalPending
+	// EvalInvalid means the expression can never be evaluated; the reasons have been passed to ReportError.
+	EvalInvalid
+)
+
+// EvalResult is the result of Expr.Resolve.
+type EvalResult struct {
+	Status	EvalStatus
+	Value	uint64		// only valid if Status == EvalResolved
+	Pending	[]string		// if Status == EvalPending, the names the expression is waiting on, in order of first use
+}
+
+// PendingNameHandler is an EvaluateHandler that can also tell if a name that LookupName did not find may still be defined later, such as a label that appears further down in the source.
+type PendingNameHandler interface {
+	EvaluateHandler
+	NamePending(name string) bool
+}
+
+// Resolve evaluates e as far as possible, as the assembler does in its single pass.
+// Unlike Evaluate, names for which handler.NamePending returns true are not errors; instead, the result says which names are needed before e can be evaluated.
+func (e *Expr) Resolve(handler PendingNameHandler) EvalResult {
+	return e.evaluate(handler, handler.NamePending)
+}
+
+func (e *Expr) evaluate(handler EvaluateHandler, namePending func(name string) bool) EvalResult {
 	if !e.finished {
 		// this also enforces the precondition that the stack will always have the right number of entries
-		handler.ReportError(ErrEvaluatingUnfinishedExpr)
-		return 0, false
+		handler.ReportError(e.Pos(), ErrEvaluatingUnfinishedExpr)
+		return EvalResult{Status: EvalInvalid}
 	}
 	stack := make([]uint64, 0, 16)
+	pending := make([]bool, 0, 16)		// whether each stack entry depends on a pending name
+	opPending := false				// whether any operand of the current operation depends on a pending name
+	topPending := false				// whether the last operand (for instance, a divisor) depends on a pending name
+	push := func(v uint64) {
+		stack = append(stack, v)
+		pending = append(pending, opPending)
+	}
 	pop := func() (v uint64) {
 		i := len(stack) - 1
 		v = stack[i]
+		opPending = pending[i]
+		topPending = opPending
 		stack = stack[:i]
+		pending = pending[:i]
 		return v
 	}
 	pop2 := func() (a uint64, b uint64) {
 		i := len(stack) - 2
 		a = stack[i]
 		b = stack[i + 1]
+		opPending = pending[i] || pending[i + 1]
+		topPending = pending[i + 1]
 		stack = stack[:i]
+		pending = pending[:i]
 		return a, b
 	}
+	var pendingNames []string
 	noError := true
 	for _, op := range e.ops {
 		switch op.code {
 		case ExprInt:
-			stack = append(stack, op.int)
+			opPending = false
+			push(op.int)
 		case ExprName:
 			val, ok := handler.LookupName(op.str)
+			opPending = false
 			if !ok {
-				handler.ReportError(UnknownNameError(op.str))
-				noError = false
+				if namePending != nil && namePending(op.str) {
+					opPending = true
+					if !containsString(pendingNames, op.str) {
+						pendingNames = append(pendingNames, op.str)
+					}
+				} else {
+					handler.ReportError(op.pos, UnknownNameError(op.str))
+					noError = false
+				}
 				val = 1		// don't stop evaluation
 			}
-			stack = append(stack, val)
+			push(val)
 		case ExprNeg:
 			val := pop()
 			val = ^val + 1
-			stack = append(stack, val)
+			push(val)
 		case ExprNot:
 			val := pop()
 			if val != 0 {
@@ -410,89 +525,111 @@ func (e *Expr) Evaluate(handler EvaluateHandler) (val uint64, ok bool) {
 			} else {
 				val = 1
 			}
-			stack = append(stack, val)
+			push(val)
 		case ExprCmpl:
 			val := pop()
 			val = ^val
-			stack = append(stack, val)
+			push(val)
 		case ExprMul:
 			a, b := pop2()
-			stack = append(stack, a * b)
+			push(a * b)
 		case ExprDiv:
 			a, b := pop2()
 			if b == 0 {
-				handler.ReportError(ErrZeroDivisor)
-				noError = false
+				if !topPending {
+					handler.ReportError(op.pos, ErrZeroDivisor)
+					noError = false
+				}
 				b = 1			// don't stop evaluation
 			}
-			stack = append(stack, a / b)
+			push(a / b)
 		case ExprMod:
 			a, b := pop2()
 			if b == 0 {
-				handler.ReportError(ErrZeroDivisorMod)
-				noError = false
+				if !topPending {
+					handler.ReportError(op.pos, ErrZeroDivisorMod)
+					noError = false
+				}
 				b = 1			// don't stop evaluation
 			}
-			stack = append(stack, a % b)
+			push(a % b)
 		case ExprShl:
 			a, b := pop2()
-			stack = append(stack, a << b)
+			push(a << b)
 		case ExprShr:
 			a, b := pop2()
-			stack = append(stack, a >> b)
+			push(a >> b)
 		case ExprBAnd:
 			a, b := pop2()
-			stack = append(stack, a & b)
+			push(a & b)
 		case ExprAdd:
 			a, b := pop2()
-			stack = append(stack, a + b)
+			push(a + b)
 		case ExprSub:
 			a, b := pop2()
-			stack = append(stack, a - b)
+			push(a - b)
 		case ExprBOr:
 			a, b := pop2()
-			stack = append(stack, a | b)
+			push(a | b)
 		case ExprBXor:
 			a, b := pop2()
-			stack = append(stack, a ^ b)
+			push(a ^ b)
 		case ExprEq:
 			a, b := pop2()
-			stack = append(stack, boolval(int64(a) == int64(b)))
+			push(boolval(int64(a) == int64(b)))
 		case ExprNe:
 			a, b := pop2()
-			stack = append(stack, boolval(int64(a) != int64(b)))
+			push(boolval(int64(a) != int64(b)))
 		case ExprLt:
 			a, b := pop2()
-			stack = append(stack, boolval(int64(a) < int64(b)))
+			push(boolval(int64(a) < int64(b)))
 		case ExprLe:
 			a, b := pop2()
-			stack = append(stack, boolval(int64(a) <= int64(b)))
+			push(boolval(int64(a) <= int64(b)))
 		case ExprGt:
 			a, b := pop2()
-			stack = append(stack, boolval(int64(a) > int64(b)))
+			push(boolval(int64(a) > int64(b)))
 		case ExprGe:
 			a, b := pop2()
-			stack = append(stack, boolval(int64(a) >= int64(b)))
+			push(boolval(int64(a) >= int64(b)))
 		case ExprLAnd:
 			a, b := pop2()
 			val := uint64(0)
 			if a != 0 && b != 0 {
 				val = 1
 			}
-			stack = append(stack, val)
+			push(val)
 		case ExprLOr:
 			a, b := pop2()
 			val := uint64(1)
 			if a == 0 && b == 0 {
 				val = 0
 			}
-			stack = append(stack, val)
+			push(val)
 		default:
 			panic("can't happen; likely missing new opcode implementation in Evaluate()")
 		}
 	}
 	if !noError {
-		return 0, false
+		return EvalResult{Status: EvalInvalid}
+	}
+	if pending[0] {
+		return EvalResult{
+			Status:	EvalPending,
+			Pending:	pendingNames,
+		}
+	}
+	return EvalResult{
+		Status:	EvalResolved,
+		Value:	stack[0],
+	}
+}
+
+func containsString(list []string, str string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
+		}
 	}
-	return stack[0], true
+	return false
 }
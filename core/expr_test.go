@@ -4,27 +4,29 @@ package core
 import (
 	"testing"
 	"bytes"
+	gotoken "go/token"
 
 	// TODO this is BSD 3-clause, which is technically not MIT compatible
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 )
 
 func mustAdd(t *testing.T, e *Expr, op ExprOpcode) {
-	err := e.Add(op)
+	err := e.Add(gotoken.NoPos, op)
 	if err != nil {
 		t.Fatalf("error adding %v to expression in mk function: %v", op, err)
 	}
 }
 
 func mustAddInt(t *testing.T, e *Expr, n uint64) {
-	err := e.AddInt(n)
+	err := e.AddInt(gotoken.NoPos, n)
 	if err != nil {
 		t.Fatalf("error adding int %d to expression in mk function: %v", n, err)
 	}
 }
 
 func mustAddName(t *testing.T, e *Expr, name string) {
-	err := e.AddName(name)
+	err := e.AddName(gotoken.NoPos, name)
 	if err != nil {
 		t.Fatalf("error adding name %q to expression in mk function: %v", name, err)
 	}
@@ -214,6 +216,7 @@ var goodExprCases = []struct {
 
 type testEvalHandler struct {
 	errs		[]error
+	poses	[]gotoken.Pos
 }
 
 func (h *testEvalHandler) LookupName(name string) (val uint64, ok bool) {
@@ -223,8 +226,9 @@ func (h *testEvalHandler) LookupName(name string) (val uint64, ok bool) {
 	return 0, false
 }
 
-func (h *testEvalHandler) ReportError(err error) {
+func (h *testEvalHandler) ReportError(pos gotoken.Pos, err error) {
 	h.errs = append(h.errs, err)
+	h.poses = append(h.poses, pos)
 }
 
 func testRead(t *testing.T, data []byte) *Expr {
@@ -334,3 +338,249 @@ func TestExprStableInversionReadWriteReadEval(t *testing.T) {
 }
 
 // TODO all the error conditions
+
+func TestExprV2StringTable(t *testing.T) {
+	mk := func(t *testing.T) *Expr {
+		e := NewExpr()
+		mustAddName(t, e, "KnownName")
+		mustAddName(t, e, "KnownName")
+		mustAdd(t, e, ExprAdd)
+		mustFinish(t, e)
+		return e
+	}
+	want := []byte{
+		0, byte(ExprVersion2), byte(ExprStrings), 3,
+		byte(ExprName), 0,
+		byte(ExprName), 0,
+		byte(ExprAdd),
+	}
+	strs := NewStringTable()
+	c := &ExprCodec{
+		Flags:	ExprStrings,
+		Strings:	strs,
+	}
+	b := &bytes.Buffer{}
+	_, err := c.WriteExpr(b, mk(t))
+	if err != nil {
+		t.Fatalf("WriteExpr() failed: %v", err)
+	}
+	if diff := cmp.Diff(b.Bytes(), want); diff != "" {
+		t.Errorf("WriteExpr() wrote wrong data: (-got +want)\n%v", diff)
+	}
+	if strs.Len() != 1 {
+		t.Errorf("string table has wrong size: got %d, want 1", strs.Len())
+	}
+
+	tb := &bytes.Buffer{}
+	strs.WriteTo(tb)
+	strs2 := NewStringTable()
+	_, err = strs2.ReadFrom(tb)
+	if err != nil {
+		t.Fatalf("StringTable.ReadFrom() failed: %v", err)
+	}
+	e, n, err := (&ExprCodec{Strings: strs2}).ReadExpr(bytes.NewReader(want))
+	if err != nil {
+		t.Fatalf("ReadExpr() failed: %v", err)
+	} else if n != int64(len(want)) {
+		t.Fatalf("ReadExpr() read wrong amount: got %d, want %d", n, len(want))
+	}
+	testEval(t, e, 10, nil)
+
+	_, err = testReadErr(want)
+	if err == nil {
+		t.Errorf("ReadFrom() without a string table succeeded; want error")
+	}
+}
+
+func TestExprV2Positions(t *testing.T) {
+	fset := gotoken.NewFileSet()
+	f := fset.AddFile("test.s", -1, 32)
+	f.AddLine(16)
+	e := NewExpr()
+	err := e.AddInt(f.Pos(20), 5)
+	if err != nil {
+		t.Fatalf("AddInt() failed: %v", err)
+	}
+	mustAdd(t, e, ExprNeg)
+	mustFinish(t, e)
+
+	b := &bytes.Buffer{}
+	_, err = (&ExprCodec{Flags: ExprPositions, FileSet: fset}).WriteExpr(b, e)
+	if err != nil {
+		t.Fatalf("WriteExpr() failed: %v", err)
+	}
+	fset2 := gotoken.NewFileSet()
+	e2, _, err := (&ExprCodec{FileSet: fset2}).ReadExpr(b)
+	if err != nil {
+		t.Fatalf("ReadExpr() failed: %v", err)
+	}
+	got := []gotoken.Position{fset2.Position(e2.ops[0].pos), fset2.Position(e2.ops[1].pos)}
+	want := []gotoken.Position{{
+		Filename:	"test.s",
+		Line:		2,
+		Column:	5,
+	}, {}}
+	if diff := cmp.Diff(got, want, cmpopts.IgnoreFields(gotoken.Position{}, "Offset")); diff != "" {
+		t.Errorf("ReadExpr() read wrong positions: (-got +want)\n%v", diff)
+	}
+	testEval(t, e2, neg5Unsigned, nil)
+}
+
+func testReadErr(data []byte) (*Expr, error) {
+	e := NewExpr()
+	_, err := e.ReadFrom(bytes.NewReader(data))
+	return e, err
+}
+
+func TestExprVersionErrors(t *testing.T) {
+	_, err := testReadErr([]byte{0, 3})
+	if diff := cmp.Diff(err, error(UnsupportedExprVersionError(3))); diff != "" {
+		t.Errorf("reading version 3 returned wrong error: (-got +want)\n%v", diff)
+	}
+	_, err = testReadErr([]byte{1, 0xF0})
+	if diff := cmp.Diff(err, error(&ExprOpcodeVersionError{Opcode: 0xF0, Version: ExprVersion1})); diff != "" {
+		t.Errorf("reading unknown version 1 opcode returned wrong error: (-got +want)\n%v", diff)
+	}
+	_, err = testReadErr([]byte{0, 2, 0, 1, 0xF0})
+	if diff := cmp.Diff(err, error(&ExprOpcodeVersionError{Opcode: 0xF0, Version: ExprVersion2})); diff != "" {
+		t.Errorf("reading unknown version 2 opcode returned wrong error: (-got +want)\n%v", diff)
+	}
+}
+
+func TestExprErrorPositions(t *testing.T) {
+	fset := gotoken.NewFileSet()
+	f := fset.AddFile("test.s", -1, 16)
+	e := NewExpr()
+	for _, err := range []error{
+		e.AddInt(f.Pos(0), 5),
+		e.AddName(f.Pos(4), "UnknownName"),
+		e.AddInt(f.Pos(10), 0),
+		e.Add(f.Pos(8), ExprDiv),
+		e.Add(f.Pos(2), ExprAdd),
+	} {
+		if err != nil {
+			t.Fatalf("error building expression: %v", err)
+		}
+	}
+	mustFinish(t, e)
+	h := &testEvalHandler{}
+	_, ok := e.Evaluate(h)
+	if ok {
+		t.Errorf("Evaluate() succeeded; want failure")
+	}
+	if diff := cmp.Diff(h.errs, []error{UnknownNameError("UnknownName"), ErrZeroDivisor}, cmpopts.EquateErrors()); diff != "" {
+		t.Errorf("Evaluate() returned wrong errors: (-got +want)\n%v", diff)
+	}
+	if diff := cmp.Diff(h.poses, []gotoken.Pos{f.Pos(4), f.Pos(8)}); diff != "" {
+		t.Errorf("Evaluate() returned wrong error positions: (-got +want)\n%v", diff)
+	}
+	if e.Pos() != f.Pos(0) {
+		t.Errorf("Pos() wrong: got %v, want %v", e.Pos(), f.Pos(0))
+	}
+}
+
+type testResolveHandler struct {
+	testEvalHandler
+}
+
+func (h *testResolveHandler) NamePending(name string) bool {
+	return name == "Forward" || name == "Forward2"
+}
+
+func TestExprResolve(t *testing.T) {
+	mk := func(t *testing.T, names ...string) *Expr {
+		// (names[0] + names[1] + ...) / (names[len - 1] - names[len - 1])
+		e := NewExpr()
+		for i, name := range names {
+			mustAddName(t, e, name)
+			if i != 0 {
+				mustAdd(t, e, ExprAdd)
+			}
+		}
+		mustAddName(t, e, names[len(names) - 1])
+		mustAddName(t, e, names[len(names) - 1])
+		mustAdd(t, e, ExprSub)
+		mustAdd(t, e, ExprDiv)
+		mustFinish(t, e)
+		return e
+	}
+	for _, tc := range []struct {
+		name		string
+		names	[]string
+		want		EvalResult
+		errs		[]error
+	}{{
+		name:	"Pending",
+		names:	[]string{"Forward", "KnownName", "Forward2", "Forward"},
+		want:	EvalResult{
+			Status:	EvalPending,
+			Pending:	[]string{"Forward", "Forward2"},
+		},
+	}, {
+		name:	"KnownDivisor",
+		names:	[]string{"Forward", "KnownName"},
+		want:	EvalResult{
+			Status:	EvalInvalid,
+		},
+		errs:		[]error{ErrZeroDivisor},
+	}, {
+		name:	"Unknown",
+		names:	[]string{"Forward", "UnknownName", "Forward"},
+		want:	EvalResult{
+			Status:	EvalInvalid,
+		},
+		errs:		[]error{UnknownNameError("UnknownName")},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := &testResolveHandler{}
+			got := mk(t, tc.names...).Resolve(h)
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("Resolve() returned wrong result: (-got +want)\n%v", diff)
+			}
+			if diff := cmp.Diff(h.errs, tc.errs, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("Resolve() returned wrong errors: (-got +want)\n%v", diff)
+			}
+		})
+	}
+
+	h := &testResolveHandler{}
+	e := NewExpr()
+	mustAddName(t, e, "KnownName")
+	mustFinish(t, e)
+	got := e.Resolve(h)
+	if got.Status != EvalResolved || got.Value != 5 {
+		t.Errorf("Resolve() of known name returned wrong result: got %+v, want value 5", got)
+	}
+}
+
+func TestExprBind(t *testing.T) {
+	e := NewExpr()
+	mustAddName(t, e, "Forward")
+	mustAddName(t, e, "Dot")
+	mustAdd(t, e, ExprSub)
+	mustFinish(t, e)
+	b := e.Bind(func(name string) (uint64, bool) {
+		if name == "Dot" {
+			return 3, true
+		}
+		return 0, false
+	})
+	got := b.Resolve(&testResolveHandler{})
+	want := EvalResult{
+		Status:	EvalPending,
+		Pending:	[]string{"Forward"},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Resolve() of bound expression returned wrong result: (-got +want)\n%v", diff)
+	}
+	h := &testEvalHandler{}
+	v, ok := b.Bind(func(name string) (uint64, bool) {
+		return 10, true
+	}).Evaluate(h)
+	if !ok || v != 7 {
+		t.Errorf("Evaluate() of bound expression returned wrong result: got %d %v, want 7 true (errors %v)", v, ok, h.errs)
+	}
+	if got := e.Resolve(&testResolveHandler{}); got.Status != EvalInvalid {
+		t.Errorf("Bind() changed the original expression: Resolve() returned %+v", got)
+	}
+}
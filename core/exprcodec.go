@@ -0,0 +1,378 @@
+// 19 october 2026
+package core
+
+import (
+	"fmt"
+	"io"
+	"encoding/binary"
+	"bytes"
+	gotoken "go/token"
+)
+
+// The original encoding of an Expr (ExprVersion1) is the number of operations followed by each operation.
+// As expressions cannot be empty, a leading zero introduces a versioned encoding instead:
+// 	0
+// 	uvarint version
+// 	uvarint flags
+// 	uvarint number of operations
+// 	each operation:
+// 		byte opcode
+// 		ExprInt: uvarint value
+// 		ExprName: string name
+// 		if ExprPositions is set: uvarint line, and if line is not 0, string filename and uvarint column
+// where each string is either an uvarint index into a StringTable (if ExprStrings is set) or an uvarint length followed by that many bytes.
+
+// ExprVersion identifies a binary encoding of an Expr.
+type ExprVersion uint64
+const (
+	ExprVersion1 ExprVersion = 1
+	ExprVersion2 ExprVersion = 2
+	ExprVersionLatest = ExprVersion2
+)
+
+// ExprFlags specifies optional parts of an ExprVersion2 encoding.
+type ExprFlags uint64
+const (
+	// ExprPositions stores the source position of each operation.
+	ExprPositions ExprFlags = 1 << iota
+	// ExprStrings stores names and filenames as indices into a StringTable.
+	ExprStrings
+
+	exprKnownFlags = ExprPositions | ExprStrings
+)
+
+// UnsupportedExprVersionError is returned when reading an expression encoded with an unknown version.
+type UnsupportedExprVersionError ExprVersion
+
+func (e UnsupportedExprVersionError) Error() string {
+	return fmt.Sprintf("unsupported expression encoding version %d (newest supported is %d)", uint64(e), ExprVersionLatest)
+}
+
+// ExprOpcodeVersionError is returned when reading an expression that uses an opcode not defined by its encoding version.
+// This usually means the expression was written by a newer version of the assembler.
+type ExprOpcodeVersionError struct {
+	Opcode	ExprOpcode
+	Version	ExprVersion
+}
+
+func (e *ExprOpcodeVersionError) Error() string {
+	return fmt.Sprintf("expression opcode 0x%X is not defined in expression encoding version %d; was this written by a newer assembler?", byte(e.Opcode), e.Version)
+}
+
+// StringTable stores each distinct string used by a set of encoded expressions once.
+// A StringTable is usually shared by all expressions in an object file, and written alongside them.
+type StringTable struct {
+	strs		[]string
+	indices	map[string]uint64
+}
+
+func NewStringTable() *StringTable {
+	return &StringTable{
+		indices:	make(map[string]uint64),
+	}
+}
+
+// Add returns the index of str, adding it to the table if it is not already present.
+func (t *StringTable) Add(str string) uint64 {
+	if i, ok := t.indices[str]; ok {
+		return i
+	}
+	i := uint64(len(t.strs))
+	t.strs = append(t.strs, str)
+	t.indices[str] = i
+	return i
+}
+
+// Lookup returns the string at index i.
+func (t *StringTable) Lookup(i uint64) (str string, ok bool) {
+	if i >= uint64(len(t.strs)) {
+		return "", false
+	}
+	return t.strs[i], true
+}
+
+func (t *StringTable) Len() int {
+	return len(t.strs)
+}
+
+func (t *StringTable) ReadFrom(r io.Reader) (n int64, err error) {
+	tr := &trackingReader{r: r}
+	t2 := NewStringTable()
+	count, err := binary.ReadUvarint(tr)
+	if err != nil {
+		return int64(tr.n), readError(err)
+	}
+	for i := uint64(0); i < count; i++ {
+		str, err := readString(tr)
+		if err != nil {
+			return int64(tr.n), err
+		}
+		if _, ok := t2.indices[str]; ok {
+			return int64(tr.n), fmt.Errorf("duplicate string %q in string table", str)
+		}
+		t2.Add(str)
+	}
+	*t = *t2
+	return int64(tr.n), nil
+}
+
+func (t *StringTable) WriteTo(w io.Writer) (n int64, err error) {
+	// the Write calls here cannot fail according to the documentation for bytes.Buffer
+	buf := new(bytes.Buffer)
+	writeUvarint(buf, uint64(len(t.strs)))
+	for _, str := range t.strs {
+		writeString(buf, str)
+	}
+	return buf.WriteTo(w)
+}
+
+func writeUvarint(buf *bytes.Buffer, n uint64) {
+	num := make([]byte, binary.MaxVarintLen64)
+	nn := binary.PutUvarint(num, n)
+	buf.Write(num[:nn])
+}
+
+func writeString(buf *bytes.Buffer, str string) {
+	writeUvarint(buf, uint64(len(str)))
+	buf.WriteString(str)
+}
+
+func readString(r *trackingReader) (string, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", readError(err)
+	}
+	buf := make([]byte, n)
+	_, err = r.readFull(buf)
+	if err != nil {
+		return "", readError(err)
+	}
+	return string(buf), nil
+}
+
+// ExprCodec reads and writes expressions using a specific encoding.
+// The zero ExprCodec writes ExprVersion2 with no flags, and reads any encoding that does not need a StringTable or FileSet.
+type ExprCodec struct {
+	// Version is the encoding version to write; 0 means ExprVersionLatest.
+	// Reading accepts all known versions regardless.
+	Version	ExprVersion
+	// Flags are the optional parts to write in ExprVersion2.
+	Flags	ExprFlags
+	// Strings is required when writing with ExprStrings, or when reading an expression written with it.
+	Strings	*StringTable
+	// FileSet is required when writing with ExprPositions, to turn each operation's position into a filename, line, and column.
+	// When reading, positions are added to FileSet if it is not nil, and discarded otherwise.
+	FileSet	*gotoken.FileSet
+}
+
+func (c *ExprCodec) version() ExprVersion {
+	if c.Version == 0 {
+		return ExprVersionLatest
+	}
+	return c.Version
+}
+
+// WriteExpr writes e to w.
+func (c *ExprCodec) WriteExpr(w io.Writer, e *Expr) (n int64, err error) {
+	switch c.version() {
+	case ExprVersion1:
+		if c.Flags != 0 {
+			return 0, fmt.Errorf("expression encoding version 1 does not support flags")
+		}
+		return e.WriteTo(w)
+	case ExprVersion2:
+		// handled below
+	default:
+		return 0, UnsupportedExprVersionError(c.version())
+	}
+	if !e.finished {
+		return 0, fmt.Errorf("cannot write unfinished expression")
+	}
+	if c.Flags & ^exprKnownFlags != 0 {
+		return 0, fmt.Errorf("unknown expression encoding flags 0x%X", uint64(c.Flags & ^exprKnownFlags))
+	}
+	if c.Flags & ExprStrings != 0 && c.Strings == nil {
+		return 0, fmt.Errorf("cannot write expression with ExprStrings without a StringTable")
+	}
+	if c.Flags & ExprPositions != 0 && c.FileSet == nil {
+		return 0, fmt.Errorf("cannot write expression with ExprPositions without a FileSet")
+	}
+
+	// the Write and WriteTo calls here cannot fail according to the documentation for bytes.Buffer
+	buf := new(bytes.Buffer)
+	buf.WriteByte(0)
+	writeUvarint(buf, uint64(ExprVersion2))
+	writeUvarint(buf, uint64(c.Flags))
+	writeUvarint(buf, uint64(len(e.ops)))
+	for _, op := range e.ops {
+		buf.WriteByte(byte(op.code))
+		if op.code == ExprInt {
+			writeUvarint(buf, op.int)
+		}
+		if op.code == ExprName {
+			c.writeString(buf, op.str)
+		}
+		if c.Flags & ExprPositions != 0 {
+			c.writePos(buf, op.pos)
+		}
+	}
+	return buf.WriteTo(w)
+}
+
+func (c *ExprCodec) writeString(buf *bytes.Buffer, str string) {
+	if c.Flags & ExprStrings != 0 {
+		writeUvarint(buf, c.Strings.Add(str))
+		return
+	}
+	writeString(buf, str)
+}
+
+func (c *ExprCodec) writePos(buf *bytes.Buffer, pos gotoken.Pos) {
+	if !pos.IsValid() {
+		writeUvarint(buf, 0)
+		return
+	}
+	p := c.FileSet.Position(pos)
+	writeUvarint(buf, uint64(p.Line))
+	c.writeString(buf, p.Filename)
+	writeUvarint(buf, uint64(p.Column))
+}
+
+// ReadExpr reads an expression of any known version from r.
+func (c *ExprCodec) ReadExpr(r io.Reader) (e *Expr, n int64, err error) {
+	tr := &trackingReader{r: r}
+	e, err = readExpr(tr, c)
+	return e, int64(tr.n), err
+}
+
+// readVersioned reads the rest of an expression after the leading zero.
+func (c *ExprCodec) readVersioned(r *trackingReader) (e *Expr, err error) {
+	v, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, readError(err)
+	}
+	version := ExprVersion(v)
+	if version != ExprVersion2 {
+		return nil, UnsupportedExprVersionError(version)
+	}
+	f, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, readError(err)
+	}
+	flags := ExprFlags(f)
+	if flags & ^exprKnownFlags != 0 {
+		// flags are only ever added alongside a new version
+		return nil, fmt.Errorf("unknown flags 0x%X in expression encoding version %d", uint64(flags & ^exprKnownFlags), version)
+	}
+	if flags & ExprStrings != 0 && c.Strings == nil {
+		return nil, fmt.Errorf("expression uses a string table but none was provided")
+	}
+
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, readError(err)
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("invalid expression read: empty expression")
+	}
+	e = NewExpr()
+	e.ops = make([]exprOp, n)
+	var positions []gotoken.Position
+	if flags & ExprPositions != 0 {
+		positions = make([]gotoken.Position, n)
+	}
+	for i := range e.ops {
+		op := &(e.ops[i])
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, readError(err)
+		}
+		op.code = ExprOpcode(b)
+		if op.code >= nExprOpcodes {
+			return nil, &ExprOpcodeVersionError{
+				Opcode:	op.code,
+				Version:	version,
+			}
+		}
+		if op.code == ExprInt {
+			op.int, err = binary.ReadUvarint(r)
+			if err != nil {
+				return nil, readError(err)
+			}
+		}
+		if op.code == ExprName {
+			op.str, err = c.readString(r, flags)
+			if err != nil {
+				return nil, err
+			}
+			op.int = uint64(len(op.str))
+		}
+		if flags & ExprPositions != 0 {
+			positions[i], err = c.readPos(r, flags)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	c.addPositions(e, positions)
+	err = e.Finish()
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression read: %v", err)
+	}
+	return e, nil
+}
+
+func (c *ExprCodec) readString(r *trackingReader, flags ExprFlags) (string, error) {
+	if flags & ExprStrings == 0 {
+		return readString(r)
+	}
+	i, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", readError(err)
+	}
+	str, ok := c.Strings.Lookup(i)
+	if !ok {
+		return "", fmt.Errorf("string index %d out of range of string table (size %d)", i, c.Strings.Len())
+	}
+	return str, nil
+}
+
+func (c *ExprCodec) readPos(r *trackingReader, flags ExprFlags) (p gotoken.Position, err error) {
+	line, err := binary.ReadUvarint(r)
+	if err != nil {
+		return p, readError(err)
+	}
+	if line == 0 {
+		return p, nil
+	}
+	p.Line = int(line)
+	p.Filename, err = c.readString(r, flags)
+	if err != nil {
+		return p, err
+	}
+	col, err := binary.ReadUvarint(r)
+	if err != nil {
+		return p, readError(err)
+	}
+	p.Column = int(col)
+	return p, nil
+}
+
+// addPositions gives each operation in e a Pos in c.FileSet that maps back to the position that was read.
+// This is done with a synthetic file that has one byte per operation, each annotated with its original position (as if by a //line directive).
+func (c *ExprCodec) addPositions(e *Expr, positions []gotoken.Position) {
+	if c.FileSet == nil || len(positions) == 0 {
+		return
+	}
+	var f *gotoken.File
+	for i, p := range positions {
+		if !p.IsValid() {
+			continue
+		}
+		if f == nil {
+			f = c.FileSet.AddFile("", -1, len(positions))
+		}
+		f.AddLineColumnInfo(i, p.Filename, p.Line, p.Column)
+		e.ops[i].pos = f.Pos(i)
+	}
+}
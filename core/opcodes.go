@@ -1,134 +1,749 @@
 // 12 december 2019
 package core
 
+import (
+	"fmt"
+	"strings"
+)
+
+// Opcode is an instruction of the 68000.
 type Opcode interface {
 	Name() string
-/*TODO
-	ValidSuffix(suffix string) bool
-	NumOperands() int
-	ValidOperand(operand Operand, which int) bool
-*/
-	// TODO opcode encoding
-}
-
-var Opcodes = []Opcode{
-/*TODO
-	Abcd{},
-	Add{},
-	Adda{},
-	Addi{},
-	Addq{},
-	Addx{},
-	And{},
-	Andi{},
-	Asl{},
-	Asr{},
-	Bcc{},
-	Bchg{},
-	Bclr{},
-	Bcs{},
-	Beq{},
-	Bge{},
-	Bgt{},
-	Bhi{},
-	Ble{},
-	Bls{},
-	Blt{},
-	Bmi{},
-	Bne{},
-	Bpl{},
-	Bra{},
-	Bset{},
-	Bsr{},
-	Btst{},
-	Bvc{},
-	Bvs{},
-	Chk{},
-	Clr{},
-	Cmp{},
-	Cmpa{},
-	Cmpi{},
-	Cmpm{},
-	Dbcc{},
-	Dbcs{},
-	Dbeq{},
-	Dbf{},
-	Dbge{},
-	Dbgt{},
-	Dbhi{},
-	Dble{},
-	Dbls{},
-	Dblt{},
-	Dbmi{},
-	Dbne{},
-	Dbpl{},
-	Dbt{},
-	Dbvc{},
-	Dbvs{},
-	Divs{},
-	Divu{},
-	Eor{},
-	Eori{},
-	Exg{},
-	Ext{},
-	Illegal{},		// $4AFC specifically
-	Jmp{},
-	Jsr{},
-	Lea{},
-	Link{},
-	Lsl{},
-	Lsr{},
-	Move{},
-	Movea{},
-	Movem{},
-	Movep{},
-	Moveq{},
-	Muls{},
-	Mulu{},
-	Nbcd{},
-	Neg{},
-	Negx{},
-	Nop{},
-	Not{},
-	Or{},
-	Ori{},
-	Pea{},
-	Reset{},
-	Rol{},
-	Ror{},
-	Roxl{},
-	Roxr{},
-	Rte{},
-	Rtr{},
-	Rts{},
-	Sbcd{},
-	Scc{},
-	Scs{},
-	Seq{},
-	Sf{},
-	Sge{},
-	Sgt{},
-	Shi{},
-	Sle{},
-	Sls{},
-	Slt{},
-	Smi{},
-	Sne{},
-	Spl{},
-	St{},
-	Stop{},
-	Sub{},
-	Suba{},
-	Subi{},
-	Subq{},
-	Subx{},
-	Svc{},
-	Svs{},
-	Swap{},
-	Tas{},
-	Trap{},
-	Trapv{},
-	Tst{},
-	Unlk{},
-*/
+
+	// ValidSize returns whether the size suffix given is allowed; SizeNone means there is no suffix.
+	ValidSize(size Size) bool
+
+	// Encode encodes the instruction with the given size suffix and operands into e.
+	// Errors in operands are returned as *OperandError, and errors in values as *ValueError.
+	Encode(e *Encoder, size Size, operands []Operand) error
+}
+
+// opcode is the Opcode of most instructions: the instructions with the same encoding differ only in their name and the bits in code.
+type opcode struct {
+	name	string
+	sizes	string		// the valid size suffixes, such as "bwl"
+	def		Size			// the size used if there is no suffix; SizeNone if one is required
+	minOps	int
+	maxOps	int
+	code	uint16
+	encode	func(o *opcode, e *Encoder, size Size, ops []Operand) error
+
+	// for instructions that have alternate encodings for address register or immediate operands, as add has adda and addi
+	codeA	uint16
+	codeI	uint16
+	hasImm	bool		// codeI is valid; ori is 0
+}
+
+func (o *opcode) Name() string {
+	return o.name
+}
+
+func (o *opcode) ValidSize(size Size) bool {
+	if size == SizeNone {
+		return true
+	}
+	return strings.Contains(o.sizes, size.String())
+}
+
+func (o *opcode) Encode(e *Encoder, size Size, ops []Operand) error {
+	if !o.ValidSize(size) {
+		if o.sizes == "" {
+			return fmt.Errorf("%s does not take a size suffix", o.name)
+		}
+		return fmt.Errorf("invalid size suffix .%v for %s (valid: .%s)", size, o.name, strings.Join(strings.Split(o.sizes, ""), ", ."))
+	}
+	if size == SizeNone {
+		size = o.def
+	}
+	if len(ops) < o.minOps || len(ops) > o.maxOps {
+		switch {
+		case o.maxOps == 0:
+			return fmt.Errorf("%s does not take operands", o.name)
+		case o.minOps == o.maxOps && o.maxOps == 1:
+			return fmt.Errorf("%s takes 1 operand, not %d", o.name, len(ops))
+		case o.minOps == o.maxOps:
+			return fmt.Errorf("%s takes %d operands, not %d", o.name, o.maxOps, len(ops))
+		}
+		return fmt.Errorf("%s takes %d to %d operands, not %d", o.name, o.minOps, o.maxOps, len(ops))
+	}
+	return o.encode(o, e, size, ops)
+}
+
+// sized returns an error if an instruction that needs a size suffix does not have one.
+func (o *opcode) sized(size Size) error {
+	if size == SizeNone && o.sizes != "" {
+		return fmt.Errorf("%s needs a size suffix", o.name)
+	}
+	return nil
+}
+
+// check returns an error if operand n is not one of the modes in set.
+func (o *opcode) check(ops []Operand, n int, set modeSet) error {
+	if set.has(ops[n].Mode) {
+		return nil
+	}
+	return &OperandError{
+		N:		n,
+		Err:		fmt.Errorf("%s cannot take %v as operand %d", o.name, ops[n].Mode, n + 1),
+	}
+}
+
+// checkAll checks each operand against its set in sets.
+func (o *opcode) checkAll(ops []Operand, sets ...modeSet) error {
+	for i, set := range sets {
+		if err := o.check(ops, i, set); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func operandError(n int, format string, args ...interface{}) error {
+	return &OperandError{
+		N:		n,
+		Err:		fmt.Errorf(format, args...),
+	}
+}
+
+// sizeBits returns the usual two-bit size field, which goes in bits 6 and 7.
+func sizeBits(size Size) uint16 {
+	switch size {
+	case SizeWord:
+		return 1 << 6
+	case SizeLong:
+		return 2 << 6
+	}
+	return 0
+}
+
+// the ways of encoding instructions
+
+// encodeFixed is for instructions without operands.
+func encodeFixed(o *opcode, e *Encoder, size Size, ops []Operand) error {
+	e.word(o.code)
+	return nil
+}
+
+// encodeEA is for instructions with one operand, an effective address, in the low six bits.
+func encodeEA(set modeSet) func(o *opcode, e *Encoder, size Size, ops []Operand) error {
+	return func(o *opcode, e *Encoder, size Size, ops []Operand) error {
+		if err := o.check(ops, 0, set); err != nil {
+			return err
+		}
+		e.word(o.code | eaBits(&ops[0]))
+		return e.ext(&ops[0], size)
+	}
+}
+
+// encodeSizedEA is encodeEA for instructions that also have the usual size field, such as clr.
+func encodeSizedEA(set modeSet) func(o *opcode, e *Encoder, size Size, ops []Operand) error {
+	return func(o *opcode, e *Encoder, size Size, ops []Operand) error {
+		if err := o.sized(size); err != nil {
+			return err
+		}
+		if err := o.check(ops, 0, set); err != nil {
+			return err
+		}
+		e.word(o.code | sizeBits(size) | eaBits(&ops[0]))
+		return e.ext(&ops[0], size)
+	}
+}
+
+// encodeReg is for instructions whose only operand is a register in the low three bits.
+func encodeReg(set modeSet) func(o *opcode, e *Encoder, size Size, ops []Operand) error {
+	return func(o *opcode, e *Encoder, size Size, ops []Operand) error {
+		if err := o.check(ops, 0, set); err != nil {
+			return err
+		}
+		e.word(o.code | uint16(ops[0].Reg))
+		return nil
+	}
+}
+
+// encodeEADn is for <ea>,dn instructions, such as chk, divs, and lea (where dn is an).
+func encodeEADn(src modeSet, dst modeSet) func(o *opcode, e *Encoder, size Size, ops []Operand) error {
+	return func(o *opcode, e *Encoder, size Size, ops []Operand) error {
+		if err := o.checkAll(ops, src, dst); err != nil {
+			return err
+		}
+		e.word(o.code | uint16(ops[1].Reg) << 9 | eaBits(&ops[0]))
+		return e.ext(&ops[0], size)
+	}
+}
+
+// encodeArith is for add, sub, and, or, cmp, and eor, which have forms for <ea>,dn and dn,<ea>, and which become their a or i variants for address register destinations and immediate sources.
+func encodeArith(src modeSet, dst modeSet) func(o *opcode, e *Encoder, size Size, ops []Operand) error {
+	return func(o *opcode, e *Encoder, size Size, ops []Operand) error {
+		s, d := &ops[0], &ops[1]
+		switch {
+		case d.Mode == AddrRegDirect && o.codeA != 0:
+			return encodeAddrArith(o, e, size, ops)
+		case s.Mode == Immediate && d.Mode != DataRegDirect && o.hasImm:
+			return encodeImmArith(o, e, size, ops)
+		}
+		if err := o.sized(size); err != nil {
+			return err
+		}
+		if d.Mode == DataRegDirect && src != 0 {
+			if err := o.check(ops, 0, src); err != nil {
+				return err
+			}
+			if s.Mode == AddrRegDirect && size == SizeByte {
+				return operandError(0, "%s.b cannot take an address register as its source", o.name)
+			}
+			e.word(o.code | uint16(d.Reg) << 9 | sizeBits(size) | eaBits(s))
+			return e.ext(s, size)
+		}
+		switch {
+		case dst == 0:
+			return operandError(1, "%s needs a data register as its destination", o.name)
+		case src == 0 && s.Mode != DataRegDirect:
+			return operandError(0, "%s needs a data register as its source", o.name)
+		case s.Mode != DataRegDirect:
+			return operandError(0, "%s needs a data register as its source or destination", o.name)
+		}
+		if err := o.check(ops, 1, dst); err != nil {
+			return err
+		}
+		e.word(o.code | uint16(s.Reg) << 9 | 4 << 6 | sizeBits(size) | eaBits(d))
+		return e.ext(d, size)
+	}
+}
+
+// encodeAddrArith is for adda, suba, and cmpa.
+func encodeAddrArith(o *opcode, e *Encoder, size Size, ops []Operand) error {
+	if err := o.sized(size); err != nil {
+		return err
+	}
+	if size == SizeByte {
+		return fmt.Errorf("%s cannot be byte-sized with an address register destination", o.name)
+	}
+	if err := o.checkAll(ops, mAll, mAn); err != nil {
+		return err
+	}
+	code := o.codeA | uint16(ops[1].Reg) << 9 | eaBits(&ops[0])
+	if size == SizeLong {
+		code |= 1 << 8
+	}
+	e.word(code)
+	return e.ext(&ops[0], size)
+}
+
+// encodeImmArith is for addi, subi, andi, ori, eori, and cmpi; andi, ori, and eori can also go to ccr and sr.
+func encodeImmArith(o *opcode, e *Encoder, size Size, ops []Operand) error {
+	d := &ops[1]
+	toSR := o.codeI != 0x0400 && o.codeI != 0x0600 && o.codeI != 0x0C00		// not subi, addi, or cmpi
+	if toSR && (d.Mode == CCRDirect || d.Mode == SRDirect) {
+		if err := o.check(ops, 0, mImm); err != nil {
+			return err
+		}
+		if d.Mode == CCRDirect {
+			if size != SizeNone && size != SizeByte {
+				return fmt.Errorf("%s to ccr must be byte-sized", o.name)
+			}
+			e.word(o.codeI | 0x3C)
+			return e.ext(&ops[0], SizeByte)
+		}
+		if size != SizeNone && size != SizeWord {
+			return fmt.Errorf("%s to sr must be word-sized", o.name)
+		}
+		e.word(o.codeI | 0x7C)
+		return e.ext(&ops[0], SizeWord)
+	}
+	if err := o.sized(size); err != nil {
+		return err
+	}
+	if err := o.checkAll(ops, mImm, mDataAlt); err != nil {
+		return err
+	}
+	e.word(o.codeI | sizeBits(size) | eaBits(d))
+	if err := e.ext(&ops[0], size); err != nil {
+		return err
+	}
+	return e.ext(d, size)
+}
+
+// encodeQuick is for addq and subq.
+func encodeQuick(o *opcode, e *Encoder, size Size, ops []Operand) error {
+	if err := o.sized(size); err != nil {
+		return err
+	}
+	if err := o.checkAll(ops, mImm, mAlt); err != nil {
+		return err
+	}
+	if ops[1].Mode == AddrRegDirect && size == SizeByte {
+		return operandError(1, "%s.b cannot take an address register as its destination", o.name)
+	}
+	off := e.word(o.code | sizeBits(size) | eaBits(&ops[1]))
+	if err := e.value(ops[0].Value, FixupQuick, off, 0); err != nil {
+		return err
+	}
+	return e.ext(&ops[1], size)
+}
+
+// encodeExtended is for addx, subx, abcd, and sbcd, which take either two data registers or two predecrements.
+func encodeExtended(o *opcode, e *Encoder, size Size, ops []Operand) error {
+	if err := o.sized(size); err != nil {
+		return err
+	}
+	if err := o.checkAll(ops, mDn | mPreDec, mDn | mPreDec); err != nil {
+		return err
+	}
+	if ops[0].Mode != ops[1].Mode {
+		return operandError(1, "%s needs both operands to be data registers or both to be predecrements", o.name)
+	}
+	code := o.code | uint16(ops[1].Reg) << 9 | sizeBits(size) | uint16(ops[0].Reg)
+	if ops[0].Mode == AddrRegPreDec {
+		code |= 1 << 3
+	}
+	e.word(code)
+	return nil
+}
+
+func encodeCmpm(o *opcode, e *Encoder, size Size, ops []Operand) error {
+	if err := o.sized(size); err != nil {
+		return err
+	}
+	if err := o.checkAll(ops, mPostInc, mPostInc); err != nil {
+		return err
+	}
+	e.word(o.code | uint16(ops[1].Reg) << 9 | sizeBits(size) | uint16(ops[0].Reg))
+	return nil
+}
+
+// encodeShift is for the shifts and rotates, which either shift a data register by a count in an immediate or a data register, or shift a word in memory by one.
+// code has the type in bits 3 and 4 and the direction in bit 8, as in the register form.
+func encodeShift(o *opcode, e *Encoder, size Size, ops []Operand) error {
+	if err := o.sized(size); err != nil {
+		return err
+	}
+	if len(ops) == 1 {
+		if size != SizeWord {
+			return fmt.Errorf("%s of memory must be word-sized", o.name)
+		}
+		if err := o.check(ops, 0, mMemAlt); err != nil {
+			return err
+		}
+		typ := (o.code >> 3) & 3
+		e.word(0xE0C0 | typ << 9 | o.code & (1 << 8) | eaBits(&ops[0]))
+		return e.ext(&ops[0], size)
+	}
+	if err := o.checkAll(ops, mDn | mImm, mDn); err != nil {
+		return err
+	}
+	code := o.code | sizeBits(size) | uint16(ops[1].Reg)
+	if ops[0].Mode == DataRegDirect {
+		e.word(code | uint16(ops[0].Reg) << 9 | 1 << 5)
+		return nil
+	}
+	return e.value(ops[0].Value, FixupQuick, e.word(code), 0)
+}
+
+// encodeBranch is for bra, bsr, and bcc.
+// Without a size, a branch is short if its target is already known to be close enough, and word-sized otherwise.
+func encodeBranch(o *opcode, e *Encoder, size Size, ops []Operand) error {
+	if err := o.check(ops, 0, mAbsW | mAbsL); err != nil {
+		return err
+	}
+	x := ops[0].Value
+	r := e.Eval(x)
+	pc := e.PC + 2
+	if size == SizeNone {
+		size = SizeWord
+		if r.Status == EvalResolved {
+			d := int64(int32(uint32(r.Value) - pc))
+			if d >= -0x80 && d <= 0x7F && d != 0 {
+				size = SizeShort
+			}
+		}
+	}
+	if size == SizeWord {
+		e.word(o.code)
+		return e.put(r, x, FixupPCRel16, e.word(0), pc)
+	}
+	return e.put(r, x, FixupBranch8, e.word(o.code) + 1, pc)
+}
+
+func encodeDbcc(o *opcode, e *Encoder, size Size, ops []Operand) error {
+	if err := o.checkAll(ops, mDn, mAbsW | mAbsL); err != nil {
+		return err
+	}
+	e.word(o.code | uint16(ops[0].Reg))
+	return e.value(ops[1].Value, FixupPCRel16, e.word(0), e.PC + 2)
+}
+
+// encodeBit is for btst, bchg, bclr, and bset, whose size is long for data registers and byte for memory.
+// code is the dynamic (bit number in a register) form; the static (immediate bit number) form is code ^ 0x0900.
+func encodeBit(o *opcode, e *Encoder, size Size, ops []Operand) error {
+	dst := mDataAlt
+	if o.name == "btst" {
+		dst = mData
+		if ops[0].Mode == Immediate {
+			dst &^= mImm
+		}
+	}
+	if err := o.checkAll(ops, mDn | mImm, dst); err != nil {
+		return err
+	}
+	d := &ops[1]
+	switch {
+	case d.Mode == DataRegDirect && size != SizeNone && size != SizeLong:
+		return fmt.Errorf("%s on a data register must be long-sized", o.name)
+	case d.Mode != DataRegDirect && size != SizeNone && size != SizeByte:
+		return fmt.Errorf("%s on memory must be byte-sized", o.name)
+	}
+	if ops[0].Mode == DataRegDirect {
+		e.word(o.code | uint16(ops[0].Reg) << 9 | eaBits(d))
+		return e.ext(d, SizeByte)
+	}
+	e.word(o.code ^ 0x0900 | eaBits(d))
+	if err := e.ext(&ops[0], SizeByte); err != nil {
+		return err
+	}
+	return e.ext(d, SizeByte)
+}
+
+func encodeExg(o *opcode, e *Encoder, size Size, ops []Operand) error {
+	if err := o.checkAll(ops, mDn | mAn, mDn | mAn); err != nil {
+		return err
+	}
+	x, y := &ops[0], &ops[1]
+	var mode uint16
+	switch {
+	case x.Mode == DataRegDirect && y.Mode == DataRegDirect:
+		mode = 0x08
+	case x.Mode == AddrRegDirect && y.Mode == AddrRegDirect:
+		mode = 0x09
+	default:
+		mode = 0x11
+		if x.Mode == AddrRegDirect {
+			// the data register goes first
+			x, y = y, x
+		}
+	}
+	e.word(o.code | uint16(x.Reg) << 9 | mode << 3 | uint16(y.Reg))
+	return nil
+}
+
+func encodeExt(o *opcode, e *Encoder, size Size, ops []Operand) error {
+	if err := o.sized(size); err != nil {
+		return err
+	}
+	if err := o.check(ops, 0, mDn); err != nil {
+		return err
+	}
+	code := o.code | uint16(ops[0].Reg)
+	if size == SizeLong {
+		code |= 1 << 6
+	}
+	e.word(code)
+	return nil
+}
+
+func encodeLink(o *opcode, e *Encoder, size Size, ops []Operand) error {
+	if err := o.checkAll(ops, mAn, mImm); err != nil {
+		return err
+	}
+	e.word(o.code | uint16(ops[0].Reg))
+	return e.value(ops[1].Value, FixupDisp16, e.word(0), 0)
+}
+
+// encodeImmOnly is for stop, whose only operand is an immediate word.
+func encodeImmOnly(o *opcode, e *Encoder, size Size, ops []Operand) error {
+	if err := o.check(ops, 0, mImm); err != nil {
+		return err
+	}
+	e.word(o.code)
+	return e.ext(&ops[0], SizeWord)
+}
+
+func encodeTrap(o *opcode, e *Encoder, size Size, ops []Operand) error {
+	if err := o.check(ops, 0, mImm); err != nil {
+		return err
+	}
+	return e.value(ops[0].Value, FixupVector, e.word(o.code), 0)
+}
+
+// moveSizeBits returns the size field of move, which is in bits 12 and 13 and does not use the usual values.
+func moveSizeBits(size Size) uint16 {
+	switch size {
+	case SizeByte:
+		return 1 << 12
+	case SizeWord:
+		return 3 << 12
+	}
+	return 2 << 12
+}
+
+func encodeMove(o *opcode, e *Encoder, size Size, ops []Operand) error {
+	s, d := &ops[0], &ops[1]
+	switch {
+	case d.Mode == CCRDirect || d.Mode == SRDirect:
+		if size != SizeNone && size != SizeWord {
+			return fmt.Errorf("move to %v must be word-sized", d.Mode)
+		}
+		if err := o.check(ops, 0, mData); err != nil {
+			return err
+		}
+		code := uint16(0x44C0)
+		if d.Mode == SRDirect {
+			code = 0x46C0
+		}
+		e.word(code | eaBits(s))
+		return e.ext(s, SizeWord)
+	case s.Mode == SRDirect:
+		if size != SizeNone && size != SizeWord {
+			return fmt.Errorf("move from sr must be word-sized")
+		}
+		if err := o.check(ops, 1, mDataAlt); err != nil {
+			return err
+		}
+		e.word(0x40C0 | eaBits(d))
+		return e.ext(d, SizeWord)
+	case s.Mode == USPDirect || d.Mode == USPDirect:
+		if size != SizeNone && size != SizeLong {
+			return fmt.Errorf("move to or from usp must be long-sized")
+		}
+		if s.Mode == USPDirect {
+			if err := o.check(ops, 1, mAn); err != nil {
+				return err
+			}
+			e.word(0x4E68 | uint16(d.Reg))
+			return nil
+		}
+		if err := o.check(ops, 0, mAn); err != nil {
+			return err
+		}
+		e.word(0x4E60 | uint16(s.Reg))
+		return nil
+	}
+
+	if err := o.sized(size); err != nil {
+		return err
+	}
+	dst := mDataAlt
+	if o.name == "movea" || d.Mode == AddrRegDirect {
+		if size == SizeByte {
+			return fmt.Errorf("%s cannot be byte-sized with an address register destination", o.name)
+		}
+		dst = mAn
+	}
+	if err := o.checkAll(ops, mAll, dst); err != nil {
+		return err
+	}
+	if s.Mode == AddrRegDirect && size == SizeByte {
+		return operandError(0, "%s.b cannot take an address register as its source", o.name)
+	}
+	dea := eaBits(d)
+	dea = (dea & 7) << 9 | (dea >> 3) << 6
+	e.word(moveSizeBits(size) | dea | eaBits(s))
+	if err := e.ext(s, size); err != nil {
+		return err
+	}
+	return e.ext(d, size)
+}
+
+func encodeMoveq(o *opcode, e *Encoder, size Size, ops []Operand) error {
+	if err := o.checkAll(ops, mImm, mDn); err != nil {
+		return err
+	}
+	return e.value(ops[0].Value, FixupMoveq, e.word(o.code | uint16(ops[1].Reg) << 9), 0)
+}
+
+// regList returns the register mask of a register list operand, which can also be a single register.
+func regList(o *Operand) uint16 {
+	switch o.Mode {
+	case DataRegDirect:
+		return 1 << o.Reg
+	case AddrRegDirect:
+		return 1 << (o.Reg + 8)
+	}
+	return o.Regs
+}
+
+func reverse16(m uint16) uint16 {
+	r := uint16(0)
+	for i := 0; i < 16; i++ {
+		if m & (1 << i) != 0 {
+			r |= 1 << (15 - i)
+		}
+	}
+	return r
+}
+
+func encodeMovem(o *opcode, e *Encoder, size Size, ops []Operand) error {
+	if err := o.sized(size); err != nil {
+		return err
+	}
+	code := o.code
+	if size == SizeLong {
+		code |= 1 << 6
+	}
+	regs := mDn | mAn | mRegList
+	if regs.has(ops[0].Mode) {
+		// registers to memory
+		if err := o.check(ops, 1, mCtrlAlt | mPreDec); err != nil {
+			return err
+		}
+		mask := regList(&ops[0])
+		if ops[1].Mode == AddrRegPreDec {
+			mask = reverse16(mask)
+		}
+		e.word(code | eaBits(&ops[1]))
+		e.word(mask)
+		return e.ext(&ops[1], size)
+	}
+	// memory to registers
+	if err := o.checkAll(ops, mCtrl | mPostInc, regs); err != nil {
+		return err
+	}
+	e.word(code | 1 << 10 | eaBits(&ops[0]))
+	e.word(regList(&ops[1]))
+	return e.ext(&ops[0], size)
+}
+
+func encodeMovep(o *opcode, e *Encoder, size Size, ops []Operand) error {
+	if err := o.sized(size); err != nil {
+		return err
+	}
+	mem := mDisp | mInd
+	var dn int
+	var m *Operand
+	opmode := uint16(4)
+	if ops[0].Mode == DataRegDirect {
+		if err := o.check(ops, 1, mem); err != nil {
+			return err
+		}
+		dn, m = ops[0].Reg, &ops[1]
+		opmode = 6
+	} else {
+		if err := o.checkAll(ops, mem, mDn); err != nil {
+			return err
+		}
+		dn, m = ops[1].Reg, &ops[0]
+	}
+	if size == SizeLong {
+		opmode++
+	}
+	e.word(o.code | uint16(dn) << 9 | opmode << 6 | uint16(m.Reg))
+	off := e.word(0)
+	if m.Mode == AddrRegIndirect {
+		// (an) is 0(an)
+		return nil
+	}
+	return e.value(m.Value, FixupDisp16, off, 0)
+}
+
+// condition codes, in the order of their encodings
+var conditions = []string{"t", "f", "hi", "ls", "cc", "cs", "ne", "eq", "vc", "vs", "pl", "mi", "ge", "lt", "gt", "le"}
+
+// Opcodes lists every instruction of the 68000.
+var Opcodes []Opcode
+
+func init() {
+	add := func(o *opcode) {
+		Opcodes = append(Opcodes, o)
+	}
+	fixed := func(name string, code uint16) {
+		add(&opcode{name: name, code: code, encode: encodeFixed})
+	}
+
+	add(&opcode{name: "abcd", sizes: "b", def: SizeByte, minOps: 2, maxOps: 2, code: 0xC100, encode: encodeExtended})
+	add(&opcode{name: "add", sizes: "bwl", minOps: 2, maxOps: 2, code: 0xD000, codeA: 0xD0C0, codeI: 0x0600, hasImm: true, encode: encodeArith(mAll, mMemAlt)})
+	add(&opcode{name: "adda", sizes: "wl", minOps: 2, maxOps: 2, codeA: 0xD0C0, encode: encodeAddrArith})
+	add(&opcode{name: "addi", sizes: "bwl", minOps: 2, maxOps: 2, codeI: 0x0600, hasImm: true, encode: encodeImmArith})
+	add(&opcode{name: "addq", sizes: "bwl", minOps: 2, maxOps: 2, code: 0x5000, encode: encodeQuick})
+	add(&opcode{name: "addx", sizes: "bwl", minOps: 2, maxOps: 2, code: 0xD100, encode: encodeExtended})
+	add(&opcode{name: "and", sizes: "bwl", minOps: 2, maxOps: 2, code: 0xC000, codeI: 0x0200, hasImm: true, encode: encodeArith(mData, mMemAlt)})
+	add(&opcode{name: "andi", sizes: "bwl", minOps: 2, maxOps: 2, codeI: 0x0200, hasImm: true, encode: encodeImmArith})
+	for _, sh := range []struct {
+		name	string
+		typ		uint16
+	}{{"as", 0}, {"ls", 1}, {"rox", 2}, {"ro", 3}} {
+		add(&opcode{name: sh.name + "l", sizes: "bwl", minOps: 1, maxOps: 2, code: 0xE000 | 1 << 8 | sh.typ << 3, encode: encodeShift})
+		add(&opcode{name: sh.name + "r", sizes: "bwl", minOps: 1, maxOps: 2, code: 0xE000 | sh.typ << 3, encode: encodeShift})
+	}
+	add(&opcode{name: "bra", sizes: "bsw", minOps: 1, maxOps: 1, code: 0x6000, encode: encodeBranch})
+	add(&opcode{name: "bsr", sizes: "bsw", minOps: 1, maxOps: 1, code: 0x6100, encode: encodeBranch})
+	for i, cc := range conditions {
+		cond := uint16(i) << 8
+		if i >= 2 {
+			add(&opcode{name: "b" + cc, sizes: "bsw", minOps: 1, maxOps: 1, code: 0x6000 | cond, encode: encodeBranch})
+		}
+		add(&opcode{name: "db" + cc, sizes: "w", def: SizeWord, minOps: 2, maxOps: 2, code: 0x50C8 | cond, encode: encodeDbcc})
+		add(&opcode{name: "s" + cc, sizes: "b", def: SizeByte, minOps: 1, maxOps: 1, code: 0x50C0 | cond, encode: encodeEA(mDataAlt)})
+	}
+	// common aliases
+	add(&opcode{name: "bhs", sizes: "bsw", minOps: 1, maxOps: 1, code: 0x6400, encode: encodeBranch})
+	add(&opcode{name: "blo", sizes: "bsw", minOps: 1, maxOps: 1, code: 0x6500, encode: encodeBranch})
+	add(&opcode{name: "dbra", sizes: "w", def: SizeWord, minOps: 2, maxOps: 2, code: 0x51C8, encode: encodeDbcc})
+	add(&opcode{name: "dbhs", sizes: "w", def: SizeWord, minOps: 2, maxOps: 2, code: 0x54C8, encode: encodeDbcc})
+	add(&opcode{name: "dblo", sizes: "w", def: SizeWord, minOps: 2, maxOps: 2, code: 0x55C8, encode: encodeDbcc})
+	add(&opcode{name: "shs", sizes: "b", def: SizeByte, minOps: 1, maxOps: 1, code: 0x54C0, encode: encodeEA(mDataAlt)})
+	add(&opcode{name: "slo", sizes: "b", def: SizeByte, minOps: 1, maxOps: 1, code: 0x55C0, encode: encodeEA(mDataAlt)})
+	for i, name := range []string{"btst", "bchg", "bclr", "bset"} {
+		add(&opcode{name: name, sizes: "bl", minOps: 2, maxOps: 2, code: 0x0100 | uint16(i) << 6, encode: encodeBit})
+	}
+	add(&opcode{name: "chk", sizes: "w", def: SizeWord, minOps: 2, maxOps: 2, code: 0x4180, encode: encodeEADn(mData, mDn)})
+	add(&opcode{name: "clr", sizes: "bwl", minOps: 1, maxOps: 1, code: 0x4200, encode: encodeSizedEA(mDataAlt)})
+	add(&opcode{name: "cmp", sizes: "bwl", minOps: 2, maxOps: 2, code: 0xB000, codeA: 0xB0C0, codeI: 0x0C00, hasImm: true, encode: encodeArith(mAll, 0)})
+	add(&opcode{name: "cmpa", sizes: "wl", minOps: 2, maxOps: 2, codeA: 0xB0C0, encode: encodeAddrArith})
+	add(&opcode{name: "cmpi", sizes: "bwl", minOps: 2, maxOps: 2, codeI: 0x0C00, hasImm: true, encode: encodeImmArith})
+	add(&opcode{name: "cmpm", sizes: "bwl", minOps: 2, maxOps: 2, code: 0xB108, encode: encodeCmpm})
+	add(&opcode{name: "divs", sizes: "w", def: SizeWord, minOps: 2, maxOps: 2, code: 0x81C0, encode: encodeEADn(mData, mDn)})
+	add(&opcode{name: "divu", sizes: "w", def: SizeWord, minOps: 2, maxOps: 2, code: 0x80C0, encode: encodeEADn(mData, mDn)})
+	add(&opcode{name: "eor", sizes: "bwl", minOps: 2, maxOps: 2, code: 0xB000, codeI: 0x0A00, hasImm: true, encode: encodeArith(0, mDataAlt)})
+	add(&opcode{name: "eori", sizes: "bwl", minOps: 2, maxOps: 2, codeI: 0x0A00, hasImm: true, encode: encodeImmArith})
+	add(&opcode{name: "exg", sizes: "l", def: SizeLong, minOps: 2, maxOps: 2, code: 0xC100, encode: encodeExg})
+	add(&opcode{name: "ext", sizes: "wl", minOps: 1, maxOps: 1, code: 0x4880, encode: encodeExt})
+	fixed("illegal", 0x4AFC)
+	add(&opcode{name: "jmp", minOps: 1, maxOps: 1, code: 0x4EC0, encode: encodeEA(mCtrl)})
+	add(&opcode{name: "jsr", minOps: 1, maxOps: 1, code: 0x4E80, encode: encodeEA(mCtrl)})
+	add(&opcode{name: "lea", sizes: "l", def: SizeLong, minOps: 2, maxOps: 2, code: 0x41C0, encode: encodeEADn(mCtrl, mAn)})
+	add(&opcode{name: "link", sizes: "w", def: SizeWord, minOps: 2, maxOps: 2, code: 0x4E50, encode: encodeLink})
+	add(&opcode{name: "move", sizes: "bwl", minOps: 2, maxOps: 2, encode: encodeMove})
+	add(&opcode{name: "movea", sizes: "wl", minOps: 2, maxOps: 2, encode: encodeMove})
+	add(&opcode{name: "movem", sizes: "wl", minOps: 2, maxOps: 2, code: 0x4880, encode: encodeMovem})
+	add(&opcode{name: "movep", sizes: "wl", minOps: 2, maxOps: 2, code: 0x0108, encode: encodeMovep})
+	add(&opcode{name: "moveq", sizes: "l", def: SizeLong, minOps: 2, maxOps: 2, code: 0x7000, encode: encodeMoveq})
+	add(&opcode{name: "muls", sizes: "w", def: SizeWord, minOps: 2, maxOps: 2, code: 0xC1C0, encode: encodeEADn(mData, mDn)})
+	add(&opcode{name: "mulu", sizes: "w", def: SizeWord, minOps: 2, maxOps: 2, code: 0xC0C0, encode: encodeEADn(mData, mDn)})
+	add(&opcode{name: "nbcd", sizes: "b", def: SizeByte, minOps: 1, maxOps: 1, code: 0x4800, encode: encodeEA(mDataAlt)})
+	add(&opcode{name: "neg", sizes: "bwl", minOps: 1, maxOps: 1, code: 0x4400, encode: encodeSizedEA(mDataAlt)})
+	add(&opcode{name: "negx", sizes: "bwl", minOps: 1, maxOps: 1, code: 0x4000, encode: encodeSizedEA(mDataAlt)})
+	fixed("nop", 0x4E71)
+	add(&opcode{name: "not", sizes: "bwl", minOps: 1, maxOps: 1, code: 0x4600, encode: encodeSizedEA(mDataAlt)})
+	add(&opcode{name: "or", sizes: "bwl", minOps: 2, maxOps: 2, code: 0x8000, codeI: 0x0000, hasImm: true, encode: encodeArith(mData, mMemAlt)})
+	add(&opcode{name: "ori", sizes: "bwl", minOps: 2, maxOps: 2, codeI: 0x0000, hasImm: true, encode: encodeImmArith})
+	add(&opcode{name: "pea", sizes: "l", def: SizeLong, minOps: 1, maxOps: 1, code: 0x4840, encode: encodeEA(mCtrl)})
+	fixed("reset", 0x4E70)
+	fixed("rte", 0x4E73)
+	fixed("rtr", 0x4E77)
+	fixed("rts", 0x4E75)
+	add(&opcode{name: "sbcd", sizes: "b", def: SizeByte, minOps: 2, maxOps: 2, code: 0x8100, encode: encodeExtended})
+	add(&opcode{name: "stop", minOps: 1, maxOps: 1, code: 0x4E72, encode: encodeImmOnly})
+	add(&opcode{name: "sub", sizes: "bwl", minOps: 2, maxOps: 2, code: 0x9000, codeA: 0x90C0, codeI: 0x0400, hasImm: true, encode: encodeArith(mAll, mMemAlt)})
+	add(&opcode{name: "suba", sizes: "wl", minOps: 2, maxOps: 2, codeA: 0x90C0, encode: encodeAddrArith})
+	add(&opcode{name: "subi", sizes: "bwl", minOps: 2, maxOps: 2, codeI: 0x0400, hasImm: true, encode: encodeImmArith})
+	add(&opcode{name: "subq", sizes: "bwl", minOps: 2, maxOps: 2, code: 0x5100, encode: encodeQuick})
+	add(&opcode{name: "subx", sizes: "bwl", minOps: 2, maxOps: 2, code: 0x9100, encode: encodeExtended})
+	add(&opcode{name: "swap", sizes: "w", def: SizeWord, minOps: 1, maxOps: 1, code: 0x4840, encode: encodeReg(mDn)})
+	add(&opcode{name: "tas", sizes: "b", def: SizeByte, minOps: 1, maxOps: 1, code: 0x4AC0, encode: encodeEA(mDataAlt)})
+	add(&opcode{name: "trap", minOps: 1, maxOps: 1, code: 0x4E40, encode: encodeTrap})
+	fixed("trapv", 0x4E76)
+	add(&opcode{name: "tst", sizes: "bwl", minOps: 1, maxOps: 1, code: 0x4A00, encode: encodeSizedEA(mDataAlt)})
+	add(&opcode{name: "unlk", minOps: 1, maxOps: 1, code: 0x4E58, encode: encodeReg(mAn)})
+
+	opcodesByName = make(map[string]Opcode, len(Opcodes))
+	for _, o := range Opcodes {
+		opcodesByName[o.Name()] = o
+	}
+}
+
+var opcodesByName map[string]Opcode
+
+// LookupOpcode returns the Opcode with the given name, which has no size suffix and is in lowercase.
+func LookupOpcode(name string) (Opcode, bool) {
+	o, ok := opcodesByName[name]
+	return o, ok
 }
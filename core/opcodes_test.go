@@ -0,0 +1,234 @@
+// 19 october 2026
+package core
+
+import (
+	"fmt"
+	"testing"
+)
+
+func testIntExpr(t *testing.T, v int64) *Expr {
+	e := NewExpr()
+	mustAddInt(t, e, uint64(v))
+	mustFinish(t, e)
+	return e
+}
+
+func testNameExpr(t *testing.T, name string) *Expr {
+	e := NewExpr()
+	mustAddName(t, e, name)
+	mustFinish(t, e)
+	return e
+}
+
+// a tiny operand language for the tests, so the cases below stay readable
+func dn(n int) Operand { return Operand{Mode: DataRegDirect, Reg: n} }
+func an(n int) Operand { return Operand{Mode: AddrRegDirect, Reg: n} }
+func ind(n int) Operand { return Operand{Mode: AddrRegIndirect, Reg: n} }
+func postinc(n int) Operand { return Operand{Mode: AddrRegPostInc, Reg: n} }
+func predec(n int) Operand { return Operand{Mode: AddrRegPreDec, Reg: n} }
+func special(m AddrMode) Operand { return Operand{Mode: m} }
+func reglist(mask uint16) Operand { return Operand{Mode: RegList, Regs: mask} }
+
+type testOperand struct {
+	mode		AddrMode
+	reg		int
+	index	int
+	long		bool
+	v		int64
+	name	string
+}
+
+func (o testOperand) operand(t *testing.T) Operand {
+	op := Operand{
+		Mode:		o.mode,
+		Reg:			o.reg,
+		Index:		o.index,
+		IndexLong:	o.long,
+	}
+	if o.name != "" {
+		op.Value = testNameExpr(t, o.name)
+	} else {
+		op.Value = testIntExpr(t, o.v)
+	}
+	return op
+}
+
+var encodeCases = []struct {
+	name	string
+	size		Size
+	ops		[]interface{}		// Operand or testOperand
+	want	string
+	fixups	int
+}{
+	{"nop", SizeNone, nil, "4E71", 0},
+	{"rts", SizeNone, nil, "4E75", 0},
+	{"move", SizeLong, []interface{}{dn(0), dn(1)}, "2200", 0},
+	{"move", SizeWord, []interface{}{testOperand{mode: Immediate, v: 0x1234}, dn(0)}, "303C1234", 0},
+	{"move", SizeByte, []interface{}{postinc(0), predec(1)}, "1318", 0},
+	{"move", SizeLong, []interface{}{testOperand{mode: AddrRegDisp, reg: 0, v: 4}, ind(1)}, "22A80004", 0},
+	{"move", SizeLong, []interface{}{an(0), an(1)}, "2248", 0},
+	{"movea", SizeLong, []interface{}{an(0), an(1)}, "2248", 0},
+	{"move", SizeByte, []interface{}{testOperand{mode: AddrRegIndex, reg: 0, index: 1, v: 2}, dn(0)}, "10301002", 0},
+	{"move", SizeNone, []interface{}{special(SRDirect), dn(0)}, "40C0", 0},
+	{"move", SizeNone, []interface{}{testOperand{mode: Immediate, v: 0x2700}, special(SRDirect)}, "46FC2700", 0},
+	{"move", SizeNone, []interface{}{special(USPDirect), an(0)}, "4E68", 0},
+	{"move", SizeLong, []interface{}{an(0), special(USPDirect)}, "4E60", 0},
+	{"move", SizeLong, []interface{}{testOperand{mode: AbsLong, name: "Forward"}, dn(0)}, "203900000000", 1},
+	{"moveq", SizeNone, []interface{}{testOperand{mode: Immediate, v: -1}, dn(0)}, "70FF", 0},
+	{"lea", SizeNone, []interface{}{testOperand{mode: PCDisp, v: 8}, an(0)}, "41FA0006", 0},
+	{"lea", SizeNone, []interface{}{testOperand{mode: PCIndex, index: 8, long: true, v: 4}, an(1)}, "43FB8802", 0},
+	{"add", SizeLong, []interface{}{dn(0), dn(1)}, "D280", 0},
+	{"add", SizeWord, []interface{}{dn(1), ind(0)}, "D350", 0},
+	{"add", SizeLong, []interface{}{testOperand{mode: Immediate, v: 1}, an(0)}, "D1FC00000001", 0},
+	{"add", SizeWord, []interface{}{testOperand{mode: Immediate, v: 5}, ind(0)}, "06500005", 0},
+	{"addq", SizeLong, []interface{}{testOperand{mode: Immediate, v: 8}, dn(0)}, "5080", 0},
+	{"addq", SizeWord, []interface{}{testOperand{mode: Immediate, v: 1}, an(0)}, "5248", 0},
+	{"subq", SizeByte, []interface{}{testOperand{mode: Immediate, v: 1}, dn(0)}, "5300", 0},
+	{"cmp", SizeWord, []interface{}{dn(0), dn(1)}, "B240", 0},
+	{"cmpi", SizeByte, []interface{}{testOperand{mode: Immediate, v: 0x10}, ind(0)}, "0C100010", 0},
+	{"and", SizeWord, []interface{}{testOperand{mode: Immediate, v: 0xFF}, dn(0)}, "C07C00FF", 0},
+	{"andi", SizeNone, []interface{}{testOperand{mode: Immediate, v: 0xFE}, special(CCRDirect)}, "023C00FE", 0},
+	{"or", SizeNone, []interface{}{testOperand{mode: Immediate, v: 0x0700}, special(SRDirect)}, "007C0700", 0},
+	{"or", SizeWord, []interface{}{testOperand{mode: Immediate, v: 1}, ind(0)}, "00500001", 0},
+	{"eor", SizeLong, []interface{}{dn(0), dn(1)}, "B181", 0},
+	{"lsl", SizeWord, []interface{}{testOperand{mode: Immediate, v: 2}, dn(0)}, "E548", 0},
+	{"asr", SizeLong, []interface{}{dn(1), dn(2)}, "E2A2", 0},
+	{"ror", SizeWord, []interface{}{ind(0)}, "E6D0", 0},
+	{"roxl", SizeByte, []interface{}{testOperand{mode: Immediate, v: 1}, dn(3)}, "E313", 0},
+	{"bra", SizeWord, []interface{}{testOperand{mode: AbsLong, v: 0}}, "6000FFFE", 0},
+	{"bne", SizeShort, []interface{}{testOperand{mode: AbsLong, v: 4}}, "6602", 0},
+	{"bne", SizeNone, []interface{}{testOperand{mode: AbsLong, v: 4}}, "6602", 0},
+	{"bsr", SizeNone, []interface{}{testOperand{mode: AbsLong, name: "Forward"}}, "61000000", 1},
+	{"dbf", SizeNone, []interface{}{dn(0), testOperand{mode: AbsLong, v: 0}}, "51C8FFFE", 0},
+	{"dbra", SizeNone, []interface{}{dn(1), testOperand{mode: AbsLong, v: 0}}, "51C9FFFE", 0},
+	{"btst", SizeNone, []interface{}{testOperand{mode: Immediate, v: 3}, dn(0)}, "08000003", 0},
+	{"bset", SizeNone, []interface{}{dn(1), ind(0)}, "03D0", 0},
+	{"clr", SizeLong, []interface{}{predec(7)}, "42A7", 0},
+	{"tst", SizeByte, []interface{}{dn(0)}, "4A00", 0},
+	{"jsr", SizeNone, []interface{}{ind(0)}, "4E90", 0},
+	{"jmp", SizeNone, []interface{}{testOperand{mode: AbsShort, v: 0x1234}}, "4EF81234", 0},
+	{"movem", SizeLong, []interface{}{reglist(0x7FFF), predec(7)}, "48E7FFFE", 0},
+	{"movem", SizeLong, []interface{}{postinc(7), reglist(0x7FFF)}, "4CDF7FFF", 0},
+	{"movem", SizeWord, []interface{}{dn(0), ind(0)}, "48900001", 0},
+	{"exg", SizeNone, []interface{}{dn(0), an(1)}, "C189", 0},
+	{"exg", SizeNone, []interface{}{an(1), dn(0)}, "C189", 0},
+	{"exg", SizeNone, []interface{}{dn(1), dn(2)}, "C342", 0},
+	{"ext", SizeWord, []interface{}{dn(0)}, "4880", 0},
+	{"ext", SizeLong, []interface{}{dn(0)}, "48C0", 0},
+	{"swap", SizeNone, []interface{}{dn(0)}, "4840", 0},
+	{"trap", SizeNone, []interface{}{testOperand{mode: Immediate, v: 15}}, "4E4F", 0},
+	{"link", SizeNone, []interface{}{an(6), testOperand{mode: Immediate, v: -8}}, "4E56FFF8", 0},
+	{"unlk", SizeNone, []interface{}{an(6)}, "4E5E", 0},
+	{"movep", SizeLong, []interface{}{testOperand{mode: AddrRegDisp, reg: 0, v: 2}, dn(1)}, "03480002", 0},
+	{"abcd", SizeNone, []interface{}{predec(0), predec(1)}, "C308", 0},
+	{"addx", SizeLong, []interface{}{dn(0), dn(1)}, "D380", 0},
+	{"cmpm", SizeByte, []interface{}{postinc(0), postinc(1)}, "B308", 0},
+	{"seq", SizeNone, []interface{}{dn(0)}, "57C0", 0},
+	{"divu", SizeNone, []interface{}{dn(1), dn(0)}, "80C1", 0},
+	{"mulu", SizeNone, []interface{}{testOperand{mode: Immediate, v: 10}, dn(0)}, "C0FC000A", 0},
+	{"chk", SizeNone, []interface{}{ind(0), dn(1)}, "4390", 0},
+	{"pea", SizeNone, []interface{}{ind(0)}, "4850", 0},
+	{"stop", SizeNone, []interface{}{testOperand{mode: Immediate, v: 0x2000}}, "4E722000", 0},
+	{"addq", SizeWord, []interface{}{testOperand{mode: Immediate, name: "Forward"}, dn(0)}, "5040", 1},
+}
+
+func testOperands(t *testing.T, in []interface{}) []Operand {
+	ops := make([]Operand, len(in))
+	for i, o := range in {
+		switch o := o.(type) {
+		case Operand:
+			ops[i] = o
+		case testOperand:
+			ops[i] = o.operand(t)
+		}
+	}
+	return ops
+}
+
+func testEncoder() *Encoder {
+	return &Encoder{
+		Eval:		func(x *Expr) EvalResult {
+			return x.Resolve(&testResolveHandler{})
+		},
+	}
+}
+
+func TestEncode(t *testing.T) {
+	e := testEncoder()
+	for _, tc := range encodeCases {
+		name := tc.name
+		if tc.size != SizeNone {
+			name += "." + tc.size.String()
+		}
+		t.Run(name, func(t *testing.T) {
+			op, ok := LookupOpcode(tc.name)
+			if !ok {
+				t.Fatalf("opcode %s not found", tc.name)
+			}
+			e.Reset(0)
+			err := op.Encode(e, tc.size, testOperands(t, tc.ops))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := fmt.Sprintf("%X", e.Bytes)
+			if got != tc.want {
+				t.Errorf("wrong encoding: got %s, want %s", got, tc.want)
+			}
+			if len(e.Fixups) != tc.fixups {
+				t.Errorf("wrong number of fixups: got %d, want %d", len(e.Fixups), tc.fixups)
+			}
+		})
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	e := testEncoder()
+	for _, tc := range []struct {
+		name	string
+		size		Size
+		ops		[]interface{}
+		err		string
+	}{
+		{"add", SizeByte, []interface{}{an(0), dn(0)}, "add.b cannot take an address register as its source"},
+		{"lea", SizeNone, []interface{}{dn(0), an(0)}, "lea cannot take dn as operand 1"},
+		{"move", SizeNone, []interface{}{dn(0), dn(1)}, "move needs a size suffix"},
+		{"move", SizeShort, []interface{}{dn(0), dn(1)}, "invalid size suffix .s for move (valid: .b, .w, .l)"},
+		{"nop", SizeNone, []interface{}{dn(0)}, "nop does not take operands"},
+		{"addq", SizeWord, []interface{}{testOperand{mode: Immediate, v: 9}, dn(0)}, "quick value 9 is not between 1 and 8"},
+		{"bra", SizeShort, []interface{}{testOperand{mode: AbsLong, v: 2}}, "short branch cannot go to the next instruction"},
+		{"bra", SizeShort, []interface{}{testOperand{mode: AbsLong, v: 0x100}}, "target $100 is out of range of a byte displacement (254 bytes away)"},
+		{"exg", SizeWord, []interface{}{dn(0), dn(1)}, "invalid size suffix .w for exg (valid: .l)"},
+		{"cmp", SizeWord, []interface{}{dn(0), ind(0)}, "cmp needs a data register as its destination"},
+		{"eor", SizeWord, []interface{}{ind(0), dn(0)}, "eor needs a data register as its source"},
+	} {
+		op, _ := LookupOpcode(tc.name)
+		e.Reset(0)
+		err := op.Encode(e, tc.size, testOperands(t, tc.ops))
+		if err == nil {
+			t.Errorf("%s.%v: no error; want %q", tc.name, tc.size, tc.err)
+			continue
+		}
+		if err.Error() != tc.err {
+			t.Errorf("%s.%v: wrong error:\ngot  %q\nwant %q", tc.name, tc.size, err.Error(), tc.err)
+		}
+	}
+}
+
+func TestFixupPut(t *testing.T) {
+	b := []byte{0x50, 0x40}
+	if err := FixupQuick.Put(b, 8, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b[0] != 0x50 || b[1] != 0x40 {
+		t.Errorf("addq #8 encoded wrong: got %X, want 5040", b)
+	}
+	if err := FixupQuick.Put(b, 3, 0); err != nil || b[0] != 0x56 {
+		t.Errorf("addq #3 encoded wrong: got %X (%v), want 5640", b, err)
+	}
+	if err := FixupAbsShort.Put(b, 0xFFFF8000, 0); err != nil {
+		t.Errorf("unexpected error for sign-extended short address: %v", err)
+	}
+	if err := FixupAbsShort.Put(b, 0x8000, 0); err == nil {
+		t.Errorf("no error for $8000 as short address")
+	}
+}
@@ -1,102 +1,121 @@
 // 13 december 2019
 package core
 
-/*TODO
-type Operand interface {
-	operand()
-}
-
-type DataRegisterOperand uint
-func (DataRegisterOperand) operand() {}
-
-type AddressRegisterOperand uint
-func (AddressRegisterOperand) operand() {}
-
-type AbsoluteWordOperand uint16
-func (AbsoluteWordOperand) operand() {}
-
-type AbsoluteLongOperand uint32
-func (AbsoluteLongOperand) operand() {}
-
-type PCRelativeWithOffsetOperand uint16
-func (PCRelativeWithOffsetOperand) operand() {}
+import (
+	gotoken "go/token"
+	"strconv"
+)
 
-type IndexRegister uint
+// AddrMode is the addressing mode of an Operand.
+type AddrMode int
 const (
-	D0Word IndexRegister = iota
-	D1Word
-	D2Word
-	D3Word
-	D4Word
-	D5Word
-	D6Word
-	D7Word
-	A0Word
-	A1Word
-	A2Word
-	A3Word
-	A4Word
-	A5Word
-	A6Word
-	A7Word
-	D0Long
-	D1Long
-	D2Long
-	D3Long
-	D4Long
-	D5Long
-	D6Long
-	D7Long
-	A0Long
-	A1Long
-	A2Long
-	A3Long
-	A4Long
-	A5Long
-	A6Long
-	A7Long
+	DataRegDirect AddrMode = iota	// dn
+	AddrRegDirect				// an
+	AddrRegIndirect				// (an)
+	AddrRegPostInc				// (an)+
+	AddrRegPreDec				// -(an)
+	AddrRegDisp				// d16(an)
+	AddrRegIndex				// d8(an,xn)
+	AbsShort					// (xxx).w
+	AbsLong					// (xxx).l
+	PCDisp					// d16(pc)
+	PCIndex					// d8(pc,xn)
+	Immediate					// #xxx
+	CCRDirect					// ccr
+	SRDirect					// sr
+	USPDirect					// usp
+	RegList					// movem register list
+	nAddrModes
 )
 
-type PCRelativeWithIndexAndOffsetOperand struct {
-	Index	IndexRegister
-	Offset	uint8
+var addrModeStrings = [nAddrModes]string{
+	DataRegDirect:		"dn",
+	AddrRegDirect:		"an",
+	AddrRegIndirect:	"(an)",
+	AddrRegPostInc:		"(an)+",
+	AddrRegPreDec:		"-(an)",
+	AddrRegDisp:		"d16(an)",
+	AddrRegIndex:		"d8(an,xn)",
+	AbsShort:			"(xxx).w",
+	AbsLong:			"(xxx).l",
+	PCDisp:			"d16(pc)",
+	PCIndex:			"d8(pc,xn)",
+	Immediate:		"#xxx",
+	CCRDirect:		"ccr",
+	SRDirect:			"sr",
+	USPDirect:		"usp",
+	RegList:			"register list",
 }
-func (PCRelativeWithIndexAndOffsetOperand) operand() {}
 
-type AddressRegisterIndirectOperand uint
-func (AddressRegisterIndirectOperand) operand() {}
+func (m AddrMode) String() string {
+	if m < 0 || m >= nAddrModes {
+		return "AddrMode(" + strconv.Itoa(int(m)) + ")"
+	}
+	return addrModeStrings[m]
+}
 
-type AddressRegisterIndirectPostincrementOperand uint
-func (AddressRegisterIndirectPostincrementOperand) operand() {}
+// Operand is an instruction operand, ready to be encoded.
+type Operand struct {
+	Mode		AddrMode
+	Reg		int		// the register of a register mode, or the base register of an indirect mode, 0 to 7
 
-type AddressRegisterIndirectPredecrementOperand uint
-func (AddressRegisterIndirectPredecrementOperand) operand() {}
+	// for AddrRegIndex and PCIndex
+	Index		int		// 0 to 7 for d0 to d7, 8 to 15 for a0 to a7
+	IndexLong	bool
 
-type AddressRegisterIndirectWithOffsetOperand struct {
-	Register	xxxx
-	Offset	uint16
-}
-func (AddressRegisterIndirectWithOffsetOperand) operand() {}
+	Value	*Expr	// the displacement, absolute address, or immediate value
+	Regs		uint16	// for RegList: bit n is set for dn, and bit n + 8 for an
 
-type AddressRegisterIndirectWithIndexAndOffsetOperand struct {
-	Register	xxxxx
-	Index	IndexRegister
-	Offset	uint8
+	Pos		gotoken.Pos	// of the operand in the source, for errors
 }
-func (AddressRegisterIndirectWithIndexAndOffsetOperand) operand() {}
 
-type ImmediateOperand uint32
-func (ImmediateOperand) operand() {}
+// the groups of addressing modes that the 68000 manuals use to describe which modes an instruction allows
+type modeSet uint32
 
-type CCROperand struct{}
-func (CCROperand) operand() {}
-
-type SROperand struct{}
-func (SROperand) operand() {}
+const (
+	mDn		modeSet = 1 << DataRegDirect
+	mAn		modeSet = 1 << AddrRegDirect
+	mInd		modeSet = 1 << AddrRegIndirect
+	mPostInc	modeSet = 1 << AddrRegPostInc
+	mPreDec	modeSet = 1 << AddrRegPreDec
+	mDisp	modeSet = 1 << AddrRegDisp
+	mIndex	modeSet = 1 << AddrRegIndex
+	mAbsW	modeSet = 1 << AbsShort
+	mAbsL	modeSet = 1 << AbsLong
+	mPCDisp	modeSet = 1 << PCDisp
+	mPCIndex	modeSet = 1 << PCIndex
+	mImm	modeSet = 1 << Immediate
+	mCCR	modeSet = 1 << CCRDirect
+	mSR		modeSet = 1 << SRDirect
+	mUSP	modeSet = 1 << USPDirect
+	mRegList	modeSet = 1 << RegList
+
+	mAlt		= mDn | mAn | mInd | mPostInc | mPreDec | mDisp | mIndex | mAbsW | mAbsL
+	mAll		= mAlt | mPCDisp | mPCIndex | mImm
+	mData	= mAll &^ mAn
+	mDataAlt	= mAlt &^ mAn
+	mMemAlt	= mDataAlt &^ mDn
+	mCtrl		= mInd | mDisp | mIndex | mAbsW | mAbsL | mPCDisp | mPCIndex
+	mCtrlAlt	= mCtrl &^ (mPCDisp | mPCIndex)
+)
 
-type USPOperand struct{}
-func (USPOperand) operand() {}
+func (s modeSet) has(m AddrMode) bool {
+	return s & (1 << m) != 0
+}
 
-type MovemOperand uint16
-func (MovemOperand) operand() {}
-*/
+// eaBits returns the six-bit effective address field for o.
+func eaBits(o *Operand) uint16 {
+	switch o.Mode {
+	case AbsShort:
+		return 7 << 3 | 0
+	case AbsLong:
+		return 7 << 3 | 1
+	case PCDisp:
+		return 7 << 3 | 2
+	case PCIndex:
+		return 7 << 3 | 3
+	case Immediate:
+		return 7 << 3 | 4
+	}
+	return uint16(o.Mode) << 3 | uint16(o.Reg)
+}
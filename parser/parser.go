@@ -0,0 +1,954 @@
+// 19 october 2026
+
+// Package parser implements a parser for a68 source files.
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andlabs/a68/ast"
+	"github.com/andlabs/a68/core"
+	"github.com/andlabs/a68/scanner"
+	"github.com/andlabs/a68/token"
+)
+
+type tokenInfo struct {
+	pos		token.Pos
+	tok		token.Token
+	lit		string
+	val		uint64
+}
+
+type parser struct {
+	file		*token.File
+	src		[]byte
+	fold		bool		// whether keywords are case-insensitive
+	s		*scanner.Scanner
+	errs		scanner.ErrorList
+
+	// the current token, and the tokens after it that have been peeked at
+	tokenInfo
+	ahead	[]tokenInfo
+	prevEnd	token.Pos		// the end of the previous token
+
+	aliases	RegAliases
+	macros	Macros
+
+	// whether the current token is the terminator of the line ParseLine last returned, which is only passed when ParseLine is next called, so that the lines after it can be skipped instead
+	ended	bool
+}
+
+// bailout is panicked by error to abandon the node being parsed; try catches it.
+type bailout struct{}
+
+func newParser(fset *token.FileSet, filename string, src []byte, mode scanner.Mode, aliases RegAliases, macros Macros) *parser {
+	p := &parser{
+		file:		fset.AddFile(filename, -1, len(src)),
+		src:		src,
+		fold:		mode & (scanner.FoldKeywords | scanner.FoldSymbols) != 0,
+		ahead:	make([]tokenInfo, 0, 4),
+		aliases:	aliases,
+		macros:	macros,
+	}
+	p.s = scanner.NewScanner(p.file, src, func(pos token.Position, msg string) {
+		p.errs.Add(pos, msg)
+	}, mode &^ scanner.ScanComments)
+	p.next()
+	return p
+}
+
+// ParseFile parses the a68 source src, which is registered in fset under filename, and returns its statements.
+// The scanner is run with mode; ScanComments is ignored.
+// A syntax error does not stop parsing: the parser skips to the end of the bad operand or statement, records it as a BadOperand, BadArg, or BadStmt, and carries on.
+// ParseFile always returns the file; if there were errors, it also returns all of them, sorted, as a scanner.ErrorList.
+func ParseFile(fset *token.FileSet, filename string, src []byte, mode scanner.Mode) (f *ast.File, err error) {
+	p := NewParser(fset, filename, src, mode, RegAliases{}, Macros{})
+	f = &ast.File{
+		Name:	filename,
+	}
+	for {
+		stmts, ok := p.ParseLine()
+		if !ok {
+			break
+		}
+		f.Stmts = append(f.Stmts, stmts...)
+	}
+	return f, p.Err()
+}
+
+// RegAliases maps the names of register aliases, defined with name .equr register, to their registers.
+type RegAliases map[string]ast.Register
+
+// Macros holds the names of the macros defined with .macro.
+// A statement that starts with one of them, with or without a size suffix, is a macro invocation, whose arguments are kept as source.
+type Macros map[string]bool
+
+// Parser parses a source file one line at a time, for callers that need to act on each line before the next is parsed.
+// The assembler is one: a register alias defined in an included file has to be known before the lines after the .include are parsed.
+type Parser struct {
+	p	*parser
+}
+
+// NewParser returns a Parser for src, as ParseFile would parse it.
+// The register aliases in aliases and the macros in macros can be used in src, and the ones that src defines are added to them, so that they carry over from one file to the next.
+func NewParser(fset *token.FileSet, filename string, src []byte, mode scanner.Mode, aliases RegAliases, macros Macros) *Parser {
+	return &Parser{
+		p:	newParser(fset, filename, src, mode, aliases, macros),
+	}
+}
+
+// File returns the token.File that the source was registered as.
+func (p *Parser) File() *token.File {
+	return p.p.file
+}
+
+// ParseLine parses the next line and returns its statements, or returns false if there are no more lines.
+func (p *Parser) ParseLine() (stmts []ast.Stmt, ok bool) {
+	if p.p.ended {
+		p.p.next()
+		p.p.ended = false
+	}
+	if p.p.tok == token.EOF {
+		return nil, false
+	}
+	stmts = p.p.parseLine(nil)
+	p.p.ended = true
+	return stmts, true
+}
+
+// LineEnded returns whether the last statement that ParseLine returned was the last on its line, so that SkipLines can be called.
+func (p *Parser) LineEnded() bool {
+	t := p.p.tok
+	return p.p.ended && len(p.p.ahead) == 0 && (t == token.EOF || t == token.TERM && p.p.lit == "\n")
+}
+
+// SkipLines passes over the lines after the one ParseLine last returned, without parsing them, until stop returns true for one; the next call to ParseLine starts with that line.
+// stop is given the keyword or name at the start of each line, in lowercase if keywords are case-insensitive.
+// If stop never returns true, the rest of the file is skipped, and SkipLines returns false.
+// SkipLines must only be called if LineEnded returns true.
+func (p *Parser) SkipLines(stop func(word string) bool) bool {
+	if !p.LineEnded() {
+		panic("SkipLines() called in the middle of a line")
+	}
+	if p.p.tok == token.EOF {
+		return false
+	}
+	_, _, ok := p.p.s.SkipLines(func(line string) bool {
+		return stop(p.p.firstWord(line))
+	})
+	return ok
+}
+
+// Err returns the errors found so far, sorted, as a scanner.ErrorList, or nil if there were none.
+func (p *Parser) Err() error {
+	p.p.errs.Sort()
+	return p.p.errs.Err()
+}
+
+func (p *parser) scan() tokenInfo {
+	var t tokenInfo
+	t.pos, t.tok, t.lit = p.s.Next()
+	t.val = p.s.Value()
+	if t.tok == token.IDENT {
+		t.tok = p.aliasToken(t.lit)
+	}
+	return t
+}
+
+// aliasToken returns the register token that a register alias, possibly with an index size like d0.w has, stands for, or IDENT if lit is not an alias.
+func (p *parser) aliasToken(lit string) token.Token {
+	name, size := splitAbsSize(lit)
+	r, ok := p.aliases[name]
+	if !ok {
+		if r, ok = p.aliases[lit]; !ok {
+			return token.IDENT
+		}
+		size = token.ILLEGAL
+	}
+	switch size {
+	case token.DOT_W:
+		if r.IsAddr() {
+			return token.ADDRREG_W
+		}
+		return token.DATAREG_W
+	case token.DOT_L:
+		if r.IsAddr() {
+			return token.ADDRREG_L
+		}
+		return token.DATAREG_L
+	}
+	if r.IsAddr() {
+		return token.ADDRREG
+	}
+	return token.DATAREG
+}
+
+// register returns the register of a register token, which may be an alias.
+func (p *parser) register(lit string) ast.Register {
+	if r, ok := p.aliases[lit]; ok {
+		return r
+	}
+	if name, size := splitAbsSize(lit); size != token.ILLEGAL {
+		if r, ok := p.aliases[name]; ok {
+			return r
+		}
+	}
+	r, _ := ast.ParseRegister(lit)
+	return r
+}
+
+func (p *parser) next() {
+	if p.pos.IsValid() {
+		p.prevEnd = p.end()
+	}
+	if len(p.ahead) != 0 {
+		p.tokenInfo = p.ahead[0]
+		p.ahead = append(p.ahead[:0], p.ahead[1:]...)
+		return
+	}
+	p.tokenInfo = p.scan()
+}
+
+// peek returns the token n tokens after the current one.
+func (p *parser) peek(n int) token.Token {
+	for len(p.ahead) < n {
+		p.ahead = append(p.ahead, p.scan())
+	}
+	return p.ahead[n - 1].tok
+}
+
+// end returns the position just after the current token.
+func (p *parser) end() token.Pos {
+	if p.lit != "" {
+		return p.pos + token.Pos(len(p.lit))
+	}
+	return p.pos + token.Pos(len(p.tok.String()))
+}
+
+// try calls f, returning false if f called error.
+func (p *parser) try(f func()) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, isBailout := r.(bailout); !isBailout {
+				panic(r)
+			}
+			ok = false
+		}
+	}()
+	f()
+	return true
+}
+
+// skip advances to the next token in stop at the outermost level of parentheses, or to the end of the statement, whichever comes first.
+// It returns the end of the last token skipped, or from if there were none.
+func (p *parser) skip(from token.Pos, stop ...token.Token) token.Pos {
+	end := from
+	depth := 0
+	for p.tok != token.TERM && p.tok != token.EOF {
+		if depth == 0 {
+			for _, t := range stop {
+				if p.tok == t {
+					return end
+				}
+			}
+		}
+		switch p.tok {
+		case token.LPAREN:
+			depth++
+		case token.RPAREN:
+			if depth > 0 {
+				depth--
+			}
+		}
+		p.next()
+		end = p.prevEnd
+	}
+	return end
+}
+
+func (p *parser) error(pos token.Pos, msg string) {
+	p.errs.Add(p.file.Position(pos), msg)
+	panic(bailout{})
+}
+
+func (p *parser) errorExpected(what string) {
+	if p.tok == token.ILLEGAL {
+		// the scanner has already reported this
+		panic(bailout{})
+	}
+	found := "'" + p.tok.String() + "'"
+	switch {
+	case p.tok == token.TERM && p.lit == "\n":
+		found = "newline"
+	case p.tok == token.EOF:
+		found = "end of file"
+	case p.tok.IsLiteral(), p.tok.IsKeyword():
+		found = p.lit
+	}
+	p.error(p.pos, "expected " + what + ", found " + found)
+}
+
+func (p *parser) expect(tok token.Token) token.Pos {
+	pos := p.pos
+	if p.tok != tok {
+		p.errorExpected("'" + tok.String() + "'")
+	}
+	p.next()
+	return pos
+}
+
+// Statements.
+
+func (p *parser) expectTerm() {
+	if p.tok != token.TERM && p.tok != token.EOF {
+		p.errorExpected("end of statement")
+	}
+	p.next()
+}
+
+// parseLine parses one statement, along with any labels before it, up to its terminator, and appends them to list.
+// Anything that cannot be parsed becomes a BadStmt that extends to the terminator.
+func (p *parser) parseLine(list []ast.Stmt) []ast.Stmt {
+	for {
+		var s ast.Stmt
+		label := false
+		from := p.pos
+		ok := p.try(func() {
+			s, label = p.parseStmt()
+		})
+		if ok && s != nil {
+			list = append(list, s)
+		}
+		if ok && label {
+			continue
+		}
+		if ok {
+			from = p.pos
+			ok = p.try(func() {
+				if p.tok != token.TERM && p.tok != token.EOF {
+					p.errorExpected("end of statement")
+				}
+			})
+		}
+		if !ok {
+			list = append(list, &ast.BadStmt{
+				From:	from,
+				To:		p.skip(from),
+			})
+		}
+		return list
+	}
+}
+
+// parseStmt parses a single statement.
+// It returns a nil Stmt for an empty statement, and label is true if the statement is a label, which shares its line with the statement after it.
+func (p *parser) parseStmt() (s ast.Stmt, label bool) {
+	switch p.tok {
+	case token.TERM, token.EOF:
+		return nil, false
+	case token.IDENT:
+		switch t := p.peek(1); {
+		case t == token.COLON:
+			return p.parseLabel(ast.GlobalLabel), true
+		case t == token.ASSIGN, t == token.DIRECTIVE && p.ahead[0].lit == ".equ":
+			return p.parseAssign(), false
+		case t == token.DIRECTIVE && p.ahead[0].lit == ".equr":
+			return p.parseRegAlias(), false
+		}
+		if name, size, ok := p.macroName(p.lit); ok {
+			return p.parseMacroCall(name, size), false
+		}
+		return p.parseInstr(), false
+	case token.DATAREG, token.ADDRREG:
+		if _, ok := p.aliases[p.lit]; ok && p.peek(1) == token.DIRECTIVE && p.ahead[0].lit == ".equr" {
+			// let the assembler report the redefinition
+			return p.parseRegAlias(), false
+		}
+	case token.AT:
+		return p.parseLabel(ast.LocalLabel), true
+	case token.ADD:
+		return p.parseLabel(ast.NextLabel), true
+	case token.SUB:
+		return p.parseLabel(ast.PrevLabel), true
+	case token.OPCODE:
+		return p.parseInstr(), false
+	case token.DIRECTIVE:
+		switch p.lit {
+		case ".macro":
+			return p.parseMacro(), false
+		case ".endm":
+			p.error(p.pos, ".endm without .macro")
+		}
+		return p.parseDirective(), false
+	}
+	p.errorExpected("statement")
+	panic("unreachable")
+}
+
+func (p *parser) parseLabel(kind ast.LabelKind) *ast.LabelStmt {
+	s := &ast.LabelStmt{
+		LabelPos:	p.pos,
+		Kind:		kind,
+		Name:	p.lit,
+	}
+	if s.Name == "" {
+		s.Name = p.tok.String()
+	}
+	p.next()
+	if kind == ast.GlobalLabel || kind == ast.LocalLabel {
+		s.Colon = p.expect(token.COLON)
+	}
+	return s
+}
+
+func (p *parser) parseAssign() *ast.AssignStmt {
+	s := &ast.AssignStmt{
+		NamePos:	p.pos,
+		Name:	p.lit,
+	}
+	p.next()
+	s.OpPos = p.pos
+	s.Equ = p.tok == token.DIRECTIVE
+	p.next()
+	s.Value = p.parseExpr()
+	return s
+}
+
+func (p *parser) parseRegAlias() *ast.RegAliasStmt {
+	s := &ast.RegAliasStmt{
+		NamePos:	p.pos,
+		Name:	p.lit,
+	}
+	p.next()
+	s.OpPos = p.pos
+	p.next()
+	if p.tok != token.DATAREG && p.tok != token.ADDRREG {
+		p.errorExpected("data or address register")
+	}
+	s.RegPos = p.pos
+	s.RegLit = p.lit
+	s.Reg = p.register(p.lit)
+	p.next()
+	p.aliases[s.Name] = s.Reg
+	return s
+}
+
+func (p *parser) parseInstr() *ast.InstrStmt {
+	s := &ast.InstrStmt{
+		NamePos:	p.pos,
+		Lit:		p.lit,
+	}
+	s.Name, s.Size = ast.SplitSize(p.lit)
+	p.next()
+	if p.tok == token.TERM || p.tok == token.EOF {
+		return s
+	}
+	for {
+		s.Operands = append(s.Operands, p.parseOperandOrBad())
+		if p.tok != token.COMMA {
+			break
+		}
+		p.next()
+	}
+	return s
+}
+
+func (p *parser) parseDirective() *ast.DirectiveStmt {
+	s := &ast.DirectiveStmt{
+		NamePos:	p.pos,
+		Name:	p.lit,
+	}
+	if s.Name == ".equ" || s.Name == ".equr" {
+		p.error(p.pos, s.Name + " must follow the name being defined")
+	}
+	p.next()
+	if p.tok == token.TERM || p.tok == token.EOF {
+		return s
+	}
+	for {
+		s.Args = append(s.Args, p.parseArgOrBad())
+		if p.tok != token.COMMA {
+			break
+		}
+		p.next()
+	}
+	return s
+}
+
+func (p *parser) parseMacro() *ast.MacroStmt {
+	s := &ast.MacroStmt{
+		MacroPos:	p.pos,
+	}
+	p.next()
+	headerOK := p.try(func() {
+		if p.tok != token.IDENT {
+			p.errorExpected("macro name")
+		}
+		s.NamePos = p.pos
+		s.Name = p.lit
+		p.next()
+		for p.tok != token.TERM && p.tok != token.EOF {
+			if len(s.Params) != 0 {
+				p.expect(token.COMMA)
+			}
+			s.Params = append(s.Params, p.parseMacroParam())
+		}
+		if p.tok != token.TERM || p.lit != "\n" || len(p.ahead) != 0 {
+			p.error(p.pos, ".macro must be the last statement on its line")
+		}
+	})
+	if !headerOK {
+		// still skip the body, so that it is not reported as full of errors
+		p.skip(s.MacroPos)
+		if p.tok != token.TERM || p.lit != "\n" || len(p.ahead) != 0 {
+			panic(bailout{})
+		}
+	}
+
+	// the body is everything up to the matching .endm; it can define macros of its own
+	depth := 0
+	body, off, ok := p.s.SkipLines(func(line string) bool {
+		switch p.firstWord(line) {
+		case ".macro":
+			depth++
+		case ".endm":
+			if depth == 0 {
+				return true
+			}
+			depth--
+		}
+		return false
+	})
+	s.BodyPos = p.file.Pos(off)
+	s.Body = body
+	if !ok {
+		p.error(s.MacroPos, "missing .endm for macro " + s.Name)
+	}
+	p.next()
+	s.EndPos = p.pos
+	p.next()
+	if !headerOK {
+		panic(bailout{})
+	}
+	p.macros[s.Name] = true
+	return s
+}
+
+// firstWord returns the keyword or name at the start of line, in lowercase if keywords are case-insensitive.
+func (p *parser) firstWord(line string) string {
+	line = strings.TrimLeft(line, " \t")
+	end := strings.IndexFunc(line, func(r rune) bool {
+		return !(r == '.' || r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+	})
+	if end >= 0 {
+		line = line[:end]
+	}
+	if p.fold {
+		line = strings.ToLower(line)
+	}
+	return line
+}
+
+func (p *parser) parseMacroParam() *ast.MacroParam {
+	if p.tok != token.IDENT {
+		p.errorExpected("parameter name")
+	}
+	prm := &ast.MacroParam{
+		NamePos:	p.pos,
+		Name:	p.lit,
+	}
+	if strings.HasSuffix(prm.Name, "...") {
+		prm.Name = strings.TrimSuffix(prm.Name, "...")
+		prm.Variadic = true
+	}
+	p.next()
+	if p.tok == token.ASSIGN {
+		if prm.Variadic {
+			p.error(p.pos, "parameter " + prm.Name + "... cannot have a default")
+		}
+		p.next()
+		prm.Default = p.parseMacroArg()
+	}
+	return prm
+}
+
+// macroName returns the name and size suffix of a macro invocation, or false if lit is not the name of a macro.
+func (p *parser) macroName(lit string) (name string, size string, ok bool) {
+	if p.macros[lit] {
+		return lit, "", true
+	}
+	name, size = ast.SplitSize(lit)
+	if size != "" && p.macros[name] {
+		return name, size, true
+	}
+	return "", "", false
+}
+
+func (p *parser) parseMacroCall(name string, size string) *ast.MacroCallStmt {
+	s := &ast.MacroCallStmt{
+		NamePos:	p.pos,
+		Lit:		p.lit,
+		Name:	name,
+		Size:		size,
+	}
+	p.next()
+	if p.tok == token.TERM || p.tok == token.EOF {
+		return s
+	}
+	for {
+		s.Args = append(s.Args, p.parseMacroArg())
+		if p.tok != token.COMMA {
+			break
+		}
+		p.next()
+	}
+	return s
+}
+
+// parseMacroArg returns the source of everything up to the next comma outside parentheses, or to the end of the statement.
+func (p *parser) parseMacroArg() *ast.MacroArg {
+	a := &ast.MacroArg{
+		From:	p.pos,
+	}
+	a.To = p.skip(a.From, token.COMMA)
+	a.Text = string(p.src[p.file.Offset(a.From):p.file.Offset(a.To)])
+	return a
+}
+
+func (p *parser) parseArgOrBad() (a ast.Arg) {
+	from := p.pos
+	if p.try(func() {
+		a = p.parseArg()
+	}) {
+		return a
+	}
+	return &ast.BadArg{
+		From:	from,
+		To:		p.skip(from, token.COMMA),
+	}
+}
+
+func (p *parser) parseArg() ast.Arg {
+	if p.tok != token.STRING {
+		return p.parseExpr()
+	}
+	b, err := scanner.Unquote(p.lit)
+	if err != nil {
+		// the scanner should have caught this already
+		p.error(p.pos, err.Error())
+	}
+	x := &ast.StringLit{
+		ValuePos:	p.pos,
+		Lit:		p.lit,
+		Value:	b,
+	}
+	p.next()
+	return x
+}
+
+// Operands.
+
+func isRegList(t token.Token) bool {
+	return t == token.SUB || t == token.DIV
+}
+
+func (p *parser) parseOperandOrBad() (o ast.Operand) {
+	from := p.pos
+	if p.try(func() {
+		o = p.parseOperand()
+	}) {
+		return o
+	}
+	return &ast.BadOperand{
+		From:	from,
+		To:		p.skip(from, token.COMMA),
+	}
+}
+
+func (p *parser) parseOperand() ast.Operand {
+	switch p.tok {
+	case token.DATAREG, token.ADDRREG:
+		if isRegList(p.peek(1)) {
+			return p.parseRegList()
+		}
+		o := &ast.RegOperand{
+			RegPos:	p.pos,
+			Lit:		p.lit,
+		}
+		o.Reg = p.register(p.lit)
+		p.next()
+		return o
+	case token.DATAREG_W, token.ADDRREG_W, token.DATAREG_L, token.ADDRREG_L:
+		p.error(p.pos, "register " + p.lit + " can only have a size as an index register")
+	case token.SR, token.CCR, token.USP:
+		o := &ast.SpecialRegOperand{
+			RegPos:	p.pos,
+			Tok:		p.tok,
+			Lit:		p.lit,
+		}
+		p.next()
+		return o
+	case token.POUND:
+		o := &ast.ImmOperand{
+			Pound:	p.pos,
+		}
+		p.next()
+		o.Value = p.parseExpr()
+		return o
+	case token.LPAREN:
+		if t := p.peek(1); t == token.ADDRREG || t == token.PC {
+			return p.parseIndirect(p.pos, nil)
+		}
+	case token.SUB:
+		if p.peek(1) == token.LPAREN && p.peek(2) == token.ADDRREG {
+			from := p.pos
+			p.next()
+			o := p.parseIndirect(from, nil)
+			if o.Disp != nil || o.Index != nil {
+				p.error(from, "predecrement cannot have a displacement or index")
+			}
+			o.PreDec = true
+			return o
+		}
+	case token.IDENT:
+		if t := p.peek(1); t == token.COMMA || t == token.TERM || t == token.EOF {
+			if name, size := splitAbsSize(p.lit); size != token.ILLEGAL {
+				o := &ast.AbsOperand{
+					Addr:	p.nameExpr(p.pos, name),
+					SizePos:	p.pos + token.Pos(len(name)),
+					Size:		size,
+				}
+				p.next()
+				return o
+			}
+		}
+	}
+
+	x := p.parseExpr()
+	if p.tok == token.LPAREN {
+		return p.parseIndirect(x.Pos(), x)
+	}
+	o := &ast.AbsOperand{
+		Addr:	x,
+	}
+	if p.tok == token.DOT_W || p.tok == token.DOT_L {
+		o.SizePos = p.pos
+		o.Size = p.tok
+		p.next()
+	}
+	return o
+}
+
+// splitAbsSize splits the size suffix off an identifier like label.w, which the scanner returns as a single IDENT.
+func splitAbsSize(lit string) (name string, size token.Token) {
+	n := len(lit) - 2
+	if n <= 0 || lit[n] != '.' {
+		return lit, token.ILLEGAL
+	}
+	switch lit[n + 1] {
+	case 'w', 'W':
+		return lit[:n], token.DOT_W
+	case 'l', 'L':
+		return lit[:n], token.DOT_L
+	}
+	return lit, token.ILLEGAL
+}
+
+// parseIndirect parses the part of an indirect operand starting with its opening parenthesis.
+func (p *parser) parseIndirect(from token.Pos, disp *ast.Expr) *ast.IndirectOperand {
+	o := &ast.IndirectOperand{
+		From:	from,
+		Disp:	disp,
+	}
+	o.Lparen = p.expect(token.LPAREN)
+	switch p.tok {
+	case token.ADDRREG:
+		o.Base = p.register(p.lit)
+	case token.PC:
+		o.PC = true
+	default:
+		p.errorExpected("address register or pc")
+	}
+	p.next()
+	if p.tok == token.COMMA {
+		p.next()
+		x := &ast.IndexReg{
+			RegPos:	p.pos,
+			Lit:		p.lit,
+		}
+		switch p.tok {
+		case token.DATAREG_L, token.ADDRREG_L:
+			x.Long = true
+			fallthrough
+		case token.DATAREG, token.ADDRREG, token.DATAREG_W, token.ADDRREG_W:
+			x.Reg = p.register(p.lit)
+		default:
+			p.errorExpected("index register")
+		}
+		p.next()
+		o.Index = x
+	}
+	o.Rparen = p.expect(token.RPAREN)
+	if p.tok == token.ADD && !o.PC && o.Disp == nil && o.Index == nil {
+		o.PostInc = true
+		p.next()
+	}
+	return o
+}
+
+func (p *parser) parseRegList() *ast.RegListOperand {
+	o := &ast.RegListOperand{
+		From:	p.pos,
+	}
+	for {
+		first := p.parseListReg()
+		last := first
+		if p.tok == token.SUB {
+			p.next()
+			lastPos := p.pos
+			last = p.parseListReg()
+			if last.IsAddr() != first.IsAddr() || last < first {
+				p.error(lastPos, fmt.Sprintf("invalid register range %v-%v", first, last))
+			}
+		}
+		for r := first; r <= last; r++ {
+			o.Regs |= 1 << r
+		}
+		if p.tok != token.DIV {
+			break
+		}
+		p.next()
+	}
+	o.To = p.prevEnd
+	return o
+}
+
+func (p *parser) parseListReg() ast.Register {
+	if p.tok != token.DATAREG && p.tok != token.ADDRREG {
+		p.errorExpected("register")
+	}
+	r := p.register(p.lit)
+	p.next()
+	return r
+}
+
+// Expressions.
+
+var binaryOps = map[token.Token]core.ExprOpcode{
+	token.MUL:		core.ExprMul,
+	token.DIV:		core.ExprDiv,
+	token.MOD:	core.ExprMod,
+	token.SHL:		core.ExprShl,
+	token.SHR:		core.ExprShr,
+	token.BAND:	core.ExprBAnd,
+	token.ADD:	core.ExprAdd,
+	token.SUB:		core.ExprSub,
+	token.BOR:		core.ExprBOr,
+	token.BXOR:	core.ExprBXor,
+	token.EQ:		core.ExprEq,
+	token.NE:		core.ExprNe,
+	token.LT:		core.ExprLt,
+	token.LE:		core.ExprLe,
+	token.GT:		core.ExprGt,
+	token.GE:		core.ExprGe,
+	token.LAND:	core.ExprLAnd,
+	token.LOR:		core.ExprLOr,
+}
+
+var unaryOps = map[token.Token]core.ExprOpcode{
+	token.SUB:		core.ExprNeg,
+	token.CMPL:	core.ExprCmpl,
+	token.NOT:	core.ExprNot,
+}
+
+func (p *parser) nameExpr(pos token.Pos, name string) *ast.Expr {
+	e := core.NewExpr()
+	e.AddName(pos, name)
+	e.Finish()
+	return &ast.Expr{
+		From:	pos,
+		To:		pos + token.Pos(len(name)),
+		X:		e,
+	}
+}
+
+func (p *parser) parseExpr() *ast.Expr {
+	x := &ast.Expr{
+		From:	p.pos,
+		X:		core.NewExpr(),
+	}
+	p.parseBinaryExpr(x.X, token.LowestPrec + 1)
+	x.To = p.prevEnd
+	if err := x.X.Finish(); err != nil {
+		// this is a bug in the parser, not in the source
+		panic(fmt.Errorf("parser produced invalid expression: %v", err))
+	}
+	return x
+}
+
+func (p *parser) parseBinaryExpr(e *core.Expr, prec1 int) {
+	p.parseUnaryExpr(e)
+	for {
+		prec := p.tok.Precedence()
+		if prec < prec1 {
+			return
+		}
+		pos, op := p.pos, binaryOps[p.tok]
+		p.next()
+		p.parseBinaryExpr(e, prec + 1)
+		e.Add(pos, op)
+	}
+}
+
+func (p *parser) parseUnaryExpr(e *core.Expr) {
+	switch p.tok {
+	case token.ADD:
+		p.next()
+		p.parseUnaryExpr(e)
+		return
+	case token.SUB, token.CMPL, token.NOT:
+		pos, op := p.pos, unaryOps[p.tok]
+		p.next()
+		p.parseUnaryExpr(e)
+		e.Add(pos, op)
+		return
+	}
+	p.parsePrimaryExpr(e)
+}
+
+func (p *parser) parsePrimaryExpr(e *core.Expr) {
+	switch p.tok {
+	case token.INT, token.CHAR:
+		e.AddInt(p.pos, p.val)
+	case token.IDENT, token.AT, token.NEXT, token.PREV:
+		e.AddName(p.pos, p.lit)
+	case token.DOT:
+		e.AddName(p.pos, ".")
+	case token.LPAREN:
+		p.next()
+		p.parseBinaryExpr(e, token.LowestPrec + 1)
+		p.expect(token.RPAREN)
+		return
+	default:
+		p.errorExpected("expression")
+	}
+	p.next()
+}
+
+// ParseExpr parses a single expression, such as one given on the command line.
+func ParseExpr(fset *token.FileSet, filename string, src []byte, mode scanner.Mode) (x *ast.Expr, err error) {
+	p := newParser(fset, filename, src, mode, nil, nil)
+	ok := p.try(func() {
+		x = p.parseExpr()
+		p.expectTerm()
+		if p.tok != token.EOF {
+			p.errorExpected("end of expression")
+		}
+	})
+	if !ok {
+		x = nil
+	}
+	p.errs.Sort()
+	return x, p.errs.Err()
+}
@@ -0,0 +1,360 @@
+// 19 october 2026
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/andlabs/a68/ast"
+	"github.com/andlabs/a68/scanner"
+	"github.com/andlabs/a68/token"
+)
+
+var testNames = map[string]uint64{
+	"start":		0x100,
+	"label":		0x1234,
+	"x":			5,
+	"@loop":		0x104,
+	":-":			0x100,
+	".":			0x200,
+}
+
+type testEvalHandler struct {
+	t	*testing.T
+}
+
+func (h testEvalHandler) LookupName(name string) (uint64, bool) {
+	v, ok := testNames[name]
+	return v, ok
+}
+
+func (h testEvalHandler) ReportError(pos token.Pos, err error) {
+	h.t.Errorf("error evaluating expression: %v", err)
+}
+
+func describeExpr(t *testing.T, x *ast.Expr) string {
+	v, _ := x.X.Evaluate(testEvalHandler{t})
+	return fmt.Sprintf("%d", int64(v))
+}
+
+func describeOperand(t *testing.T, o ast.Operand) string {
+	switch o := o.(type) {
+	case *ast.RegOperand:
+		return o.Reg.String()
+	case *ast.SpecialRegOperand:
+		return o.Tok.String()
+	case *ast.ImmOperand:
+		return "#" + describeExpr(t, o.Value)
+	case *ast.AbsOperand:
+		s := describeExpr(t, o.Addr)
+		if o.Size != token.ILLEGAL {
+			s += o.Size.String()
+		}
+		return s
+	case *ast.IndirectOperand:
+		s := ""
+		if o.PreDec {
+			s = "-"
+		}
+		if o.Disp != nil {
+			s += describeExpr(t, o.Disp)
+		}
+		if o.PC {
+			s += "(pc"
+		} else {
+			s += "(" + o.Base.String()
+		}
+		if o.Index != nil {
+			size := ".w"
+			if o.Index.Long {
+				size = ".l"
+			}
+			s += "," + o.Index.Reg.String() + size
+		}
+		s += ")"
+		if o.PostInc {
+			s += "+"
+		}
+		return s
+	case *ast.RegListOperand:
+		return fmt.Sprintf("list %04X", o.Regs)
+	}
+	return fmt.Sprintf("%T", o)
+}
+
+func describeStmt(t *testing.T, s ast.Stmt) string {
+	switch s := s.(type) {
+	case *ast.LabelStmt:
+		return fmt.Sprintf("label %d %s", s.Kind, s.Name)
+	case *ast.InstrStmt:
+		ops := make([]string, len(s.Operands))
+		for i, o := range s.Operands {
+			ops[i] = describeOperand(t, o)
+		}
+		return fmt.Sprintf("instr %s %q %s", s.Name, s.Size, strings.Join(ops, " "))
+	case *ast.DirectiveStmt:
+		args := make([]string, len(s.Args))
+		for i, a := range s.Args {
+			switch a := a.(type) {
+			case *ast.StringLit:
+				args[i] = fmt.Sprintf("%q", a.Value)
+			case *ast.Expr:
+				args[i] = describeExpr(t, a)
+			default:
+				args[i] = fmt.Sprintf("%T", a)
+			}
+		}
+		return fmt.Sprintf("directive %s %s", s.Name, strings.Join(args, " "))
+	case *ast.AssignStmt:
+		return fmt.Sprintf("assign %s %v %s", s.Name, s.Equ, describeExpr(t, s.Value))
+	case *ast.RegAliasStmt:
+		return fmt.Sprintf("alias %s %v", s.Name, s.Reg)
+	case *ast.MacroStmt:
+		params := make([]string, len(s.Params))
+		for i, p := range s.Params {
+			params[i] = p.Name
+			if p.Default != nil {
+				params[i] += "=" + p.Default.Text
+			}
+			if p.Variadic {
+				params[i] += "..."
+			}
+		}
+		return fmt.Sprintf("macro %s %s %q", s.Name, strings.Join(params, " "), s.Body)
+	case *ast.MacroCallStmt:
+		args := make([]string, len(s.Args))
+		for i, a := range s.Args {
+			args[i] = a.Text
+		}
+		return fmt.Sprintf("call %s %q %q", s.Name, s.Size, args)
+	}
+	return fmt.Sprintf("%T", s)
+}
+
+const testParseSource = `start:	move.l	#1+2*3,d0
+@loop:	dbra	d1,@loop
+	lea	4(a0,d1.w),a1
+	move.w	-(sp),(a0)+
+	movem.l	d0-d3/a0/a2-a6,-(sp)
+	jmp	label.w
+	jsr	($10+2).l
+	move	sr,-2(pc,a0.l)
+x = 5
+y .equ -x << 1 | 1
+	.dc.b	"hi\n",0,~0 & $F0
++	bra	:-
+- :: rts :: nop
+	move.w	(label-start)/2,d7
+ptr .equr a2
+	move.l	4(ptr,ptr.l),(ptr)+
+	.macro	push regs, size=l, rest...
+	movem.\size	\regs,-(sp)
+	.endm
+	push.w	d0-d1/a0, , "a,b", (1,2)
+end:
+`
+
+var testParseWant = []string{
+	"label 0 start",
+	"instr move \"l\" #7 d0",
+	"label 1 @loop",
+	"instr dbra \"\" d1 260",
+	"instr lea \"\" 4(a0,d1.w) a1",
+	"instr move \"w\" -(a7) (a0)+",
+	"instr movem \"l\" list 7D0F -(a7)",
+	"instr jmp \"\" 4660.w",
+	"instr jsr \"\" 18.l",
+	"instr move \"\" sr -2(pc,a0.l)",
+	"assign x false 5",
+	"assign y true -9",
+	"directive .dc.b \"hi\\n\" 0 240",
+	"label 2 +",
+	"instr bra \"\" 256",
+	"label 3 -",
+	"instr rts \"\" ",
+	"instr nop \"\" ",
+	"instr move \"w\" 2202 d7",
+	"alias ptr a2",
+	"instr move \"l\" 4(a2,a2.l) (a2)+",
+	"macro push regs size=l rest... \"\\tmovem.\\\\size\\t\\\\regs,-(sp)\\n\"",
+	"call push \"w\" [\"d0-d1/a0\" \"\" \"\\\"a,b\\\"\" \"(1,2)\"]",
+	"label 0 end",
+}
+
+func TestParse(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := ParseFile(fset, "test.s", []byte(testParseSource), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := make([]string, len(f.Stmts))
+	for i, s := range f.Stmts {
+		got[i] = describeStmt(t, s)
+	}
+	if len(got) != len(testParseWant) {
+		t.Fatalf("wrong number of statements: got %d, want %d\n%s", len(got), len(testParseWant), strings.Join(got, "\n"))
+	}
+	for i := range got {
+		if got[i] != testParseWant[i] {
+			t.Errorf("statement %d wrong:\ngot  %s\nwant %s", i, got[i], testParseWant[i])
+		}
+	}
+}
+
+func TestParsePositions(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := ParseFile(fset, "test.s", []byte("foo:\tmove.l\t4(a0),(a1)+\n"), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	instr := f.Stmts[1].(*ast.InstrStmt)
+	for _, tc := range []struct {
+		n		ast.Node
+		pos		string
+		end		string
+	}{
+		{f.Stmts[0], "test.s:1:1", "test.s:1:5"},
+		{instr, "test.s:1:6", "test.s:1:24"},
+		{instr.Operands[0], "test.s:1:13", "test.s:1:18"},
+		{instr.Operands[1], "test.s:1:19", "test.s:1:24"},
+	} {
+		pos := fset.Position(tc.n.Pos()).String()
+		end := fset.Position(tc.n.End()).String()
+		if pos != tc.pos || end != tc.end {
+			t.Errorf("%T at wrong position: got %s-%s, want %s-%s", tc.n, pos, end, tc.pos, tc.end)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	for _, tc := range []struct {
+		src		string
+		err		string
+	}{
+		{"\tmove.l\td0,\n", "test.s:1:13: expected expression, found end of file"},
+		{"\tmove.l\td0,)\n", "test.s:1:12: expected expression, found ')'"},
+		{"\tlea\t(a0,d1.w,a1\n", "test.s:1:14: expected ')', found ','"},
+		{"\t.equ\t5\n", "test.s:1:2: .equ must follow the name being defined"},
+		{"\tmovem.l\ta3-a1,-(sp)\n", "test.s:1:13: invalid register range a3-a1"},
+		{"\tmove.l\td0 d1\n", "test.s:1:12: expected end of statement, found d1"},
+		{"\tmove.l\td0,`\n", "test.s:1:12: invalid character '`'"},
+		{"\t.macro\tm\n\tnop\n", "test.s:1:2: missing .endm for macro m"},
+		{"\t.endm\n", "test.s:1:2: .endm without .macro"},
+		{"\t.macro\tm a :: nop\n", "test.s:1:13: .macro must be the last statement on its line"},
+		{"\t.macro\tm a b\n\t.endm\n", "test.s:1:13: expected ',', found b"},
+	} {
+		fset := token.NewFileSet()
+		_, err := ParseFile(fset, "test.s", []byte(tc.src), 0)
+		if err == nil {
+			t.Errorf("%q: no error; want %q", tc.src, tc.err)
+			continue
+		}
+		if err.Error() != tc.err {
+			t.Errorf("%q: wrong error:\ngot  %q\nwant %q", tc.src, err.Error(), tc.err)
+		}
+	}
+}
+
+func TestParseExpr(t *testing.T) {
+	fset := token.NewFileSet()
+	x, err := ParseExpr(fset, "-D", []byte("x*(2+3)"), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := describeExpr(t, x); got != "25" {
+		t.Errorf("wrong value: got %s, want 25", got)
+	}
+	_, err = ParseExpr(fset, "-D", []byte("1 2"), 0)
+	if err == nil {
+		t.Errorf("no error for trailing garbage")
+	}
+}
+
+func TestParseRecovery(t *testing.T) {
+	src := "\tmove.l\td0,)\n" +
+		"\tnop\n" +
+		"foo:\t= 4\n" +
+		"\t.dc.w\t1,(2,3\n" +
+		"\trts\td0 d1\n" +
+		"\tlea\t4(a0,d1.q),a1\n"
+	wantStmts := []string{
+		"instr move \"l\" d0 *ast.BadOperand",
+		"instr nop \"\" ",
+		"label 0 foo",
+		"*ast.BadStmt",
+		"directive .dc.w 1 *ast.BadArg 3",
+		"instr rts \"\" d0",
+		"*ast.BadStmt",
+		"instr lea \"\" *ast.BadOperand a1",
+	}
+	wantErrs := []string{
+		"test.s:1:12: expected expression, found ')'",
+		"test.s:3:6: expected statement, found '='",
+		"test.s:4:12: expected ')', found ','",
+		"test.s:5:9: expected end of statement, found d1",
+		"test.s:6:11: expected index register, found d1.q",
+	}
+
+	fset := token.NewFileSet()
+	f, err := ParseFile(fset, "test.s", []byte(src), 0)
+	list, ok := err.(scanner.ErrorList)
+	if !ok {
+		t.Fatalf("wrong error type: got %T, want scanner.ErrorList", err)
+	}
+	gotErrs := make([]string, len(list))
+	for i, e := range list {
+		gotErrs[i] = e.Error()
+	}
+	if strings.Join(gotErrs, "\n") != strings.Join(wantErrs, "\n") {
+		t.Errorf("wrong errors:\ngot\n%s\nwant\n%s", strings.Join(gotErrs, "\n"), strings.Join(wantErrs, "\n"))
+	}
+	gotStmts := make([]string, len(f.Stmts))
+	for i, s := range f.Stmts {
+		gotStmts[i] = describeStmt(t, s)
+	}
+	if strings.Join(gotStmts, "\n") != strings.Join(wantStmts, "\n") {
+		t.Errorf("wrong statements:\ngot\n%s\nwant\n%s", strings.Join(gotStmts, "\n"), strings.Join(wantStmts, "\n"))
+	}
+
+	bad := f.Stmts[6].(*ast.BadStmt)
+	pos, end := fset.Position(bad.Pos()), fset.Position(bad.End())
+	if pos.String() != "test.s:5:9" || end.String() != "test.s:5:11" {
+		t.Errorf("BadStmt at wrong position: got %v-%v, want test.s:5:9-test.s:5:11", pos, end)
+	}
+}
+
+func TestParser(t *testing.T) {
+	fset := token.NewFileSet()
+	aliases := RegAliases{}
+	p := NewParser(fset, "regs.s", []byte("count .equr d3\n"), 0, aliases, Macros{})
+	for {
+		if _, ok := p.ParseLine(); !ok {
+			break
+		}
+	}
+	if err := p.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p = NewParser(fset, "main.s", []byte("\tdbf\tcount,start\n\tmove.w\td0,\n"), 0, aliases, Macros{})
+	if p.File().Name() != "main.s" {
+		t.Errorf("wrong file: got %s, want main.s", p.File().Name())
+	}
+	var got []string
+	for {
+		stmts, ok := p.ParseLine()
+		if !ok {
+			break
+		}
+		for _, s := range stmts {
+			got = append(got, describeStmt(t, s))
+		}
+	}
+	want := []string{"instr dbf \"\" d3 256", "instr move \"w\" d0 *ast.BadOperand"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("wrong statements:\ngot  %q\nwant %q", got, want)
+	}
+	if err := p.Err(); err == nil {
+		t.Errorf("no error for bad operand")
+	}
+}
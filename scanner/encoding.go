@@ -0,0 +1,139 @@
+// 19 october 2026
+package scanner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Scanner expects UTF-8, optionally starting with a byte order mark, with lines ending in LF, CRLF, or CR.
+// Source in any other encoding has to be transcoded first, and the token.File given to NewScanner has to be the size of the transcoded source; positions are then byte offsets into that.
+
+// An Encoding converts source from some encoding to UTF-8.
+type Encoding func(data []byte) ([]byte, error)
+
+// encodings are the supported source encodings, by their names and aliases in lowercase without - or _.
+var encodings = map[string]Encoding{
+	"utf8":			fromUTF8,
+	"utf16":			fromUTF16,
+	"utf16be":		fromUTF16BE,
+	"utf16le":		fromUTF16LE,
+	"latin1":			fromLatin1,
+	"l1":				fromLatin1,
+	"iso88591":		fromLatin1,
+	"windows1252":	fromWindows1252,
+	"cp1252":		fromWindows1252,
+}
+
+// LookupEncoding returns the encoding with the given name or alias, such as "latin1", "windows-1252", or "UTF-16LE".
+// Case, -, and _ do not matter.
+func LookupEncoding(name string) (Encoding, error) {
+	key := strings.ToLower(name)
+	key = strings.ReplaceAll(key, "-", "")
+	key = strings.ReplaceAll(key, "_", "")
+	e, ok := encodings[key]
+	if !ok {
+		return nil, fmt.Errorf("unknown or unsupported source encoding %q", name)
+	}
+	return e, nil
+}
+
+// Transcode converts data from the named encoding to UTF-8.
+// If name is "", data is returned unchanged unless it starts with a UTF-16 byte order mark, in which case it is converted from UTF-16.
+func Transcode(data []byte, name string) ([]byte, error) {
+	var e Encoding
+	if name == "" {
+		if !bytes.HasPrefix(data, []byte{0xFE, 0xFF}) && !bytes.HasPrefix(data, []byte{0xFF, 0xFE}) {
+			return data, nil
+		}
+		name = "UTF-16"
+		e = fromUTF16
+	} else {
+		var err error
+		e, err = LookupEncoding(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	b, err := e(data)
+	if err != nil {
+		return nil, fmt.Errorf("error converting source from %s: %v", name, err)
+	}
+	return b, nil
+}
+
+func fromUTF8(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+// fromUTF16 converts UTF-16 in the byte order given by its byte order mark, or big-endian if it has none.
+func fromUTF16(data []byte) ([]byte, error) {
+	if bytes.HasPrefix(data, []byte{0xFF, 0xFE}) {
+		return fromUTF16LE(data[2:])
+	}
+	if bytes.HasPrefix(data, []byte{0xFE, 0xFF}) {
+		data = data[2:]
+	}
+	return fromUTF16BE(data)
+}
+
+func fromUTF16BE(data []byte) ([]byte, error) {
+	return decodeUTF16(data, func(b []byte) uint16 {
+		return uint16(b[0]) << 8 | uint16(b[1])
+	})
+}
+
+func fromUTF16LE(data []byte) ([]byte, error) {
+	return decodeUTF16(data, func(b []byte) uint16 {
+		return uint16(b[1]) << 8 | uint16(b[0])
+	})
+}
+
+// decodeUTF16 converts UTF-16 whose code units are read by unit; unpaired surrogates become U+FFFD.
+func decodeUTF16(data []byte, unit func(b []byte) uint16) ([]byte, error) {
+	if len(data) % 2 != 0 {
+		return nil, errors.New("odd number of bytes")
+	}
+	units := make([]uint16, len(data) / 2)
+	for i := range units {
+		units[i] = unit(data[2 * i:])
+	}
+	b := make([]byte, 0, len(data))
+	for _, r := range utf16.Decode(units) {
+		b = utf8.AppendRune(b, r)
+	}
+	return b, nil
+}
+
+func fromLatin1(data []byte) ([]byte, error) {
+	b := make([]byte, 0, len(data))
+	for _, c := range data {
+		b = utf8.AppendRune(b, rune(c))
+	}
+	return b, nil
+}
+
+// windows1252 are the characters of Windows-1252 from $80 to $9F, where it differs from Latin-1.
+// The five bytes that Windows-1252 leaves undefined are the C1 control characters, as in Latin-1.
+var windows1252 = [32]rune{
+	0x20AC, 0x0081, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008D, 0x017D, 0x008F,
+	0x0090, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x009D, 0x017E, 0x0178,
+}
+
+func fromWindows1252(data []byte) ([]byte, error) {
+	b := make([]byte, 0, len(data))
+	for _, c := range data {
+		r := rune(c)
+		if c >= 0x80 && c < 0xA0 {
+			r = windows1252[c - 0x80]
+		}
+		b = utf8.AppendRune(b, r)
+	}
+	return b, nil
+}
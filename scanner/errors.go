@@ -2,8 +2,12 @@
 package scanner
 
 import (
+	"fmt"
 	"io"
 	goscanner "go/scanner"
+	"sort"
+
+	"github.com/andlabs/a68/token"
 )
 
 func PrintError(w io.Writer, err error) {
@@ -15,3 +19,85 @@ type Error = goscanner.Error
 type ErrorHandler = goscanner.ErrorHandler
 
 type ErrorList = goscanner.ErrorList
+
+// ErrorCollector gathers the errors of every stage of assembly, from scanning to evaluating expressions, into a single ErrorList.
+// Its Add method is an ErrorHandler, and its ReportError method satisfies core.EvaluateHandler, so an ErrorCollector can be embedded in a type that provides the LookupName half of that interface.
+type ErrorCollector struct {
+	fset		*token.FileSet
+	list		ErrorList
+
+	// Limit is the maximum number of errors returned by Err, after duplicates are removed.
+	// If there are more, the rest are replaced with a single "too many errors" error.
+	// Limit <= 0 means no limit.
+	Limit	int
+}
+
+func NewErrorCollector(fset *token.FileSet) *ErrorCollector {
+	return &ErrorCollector{
+		fset:	fset,
+	}
+}
+
+// Add adds an error with the given position and message.
+func (c *ErrorCollector) Add(pos token.Position, msg string) {
+	c.list.Add(pos, msg)
+}
+
+// ReportError adds err, which happened at pos.
+// If pos is in a file that was included or expanded from somewhere else, the message ends with a line for each step of how pos was reached, innermost first, such as "\tincluded from main.s:40:2".
+func (c *ErrorCollector) ReportError(pos token.Pos, err error) {
+	var p token.Position
+	msg := err.Error()
+	if pos.IsValid() {
+		p = c.fset.Position(pos)
+		bt := c.fset.Backtrace(pos)
+		for i := 0; i < len(bt); i++ {
+			line := c.fset.Describe(bt[i])
+			msg += "\n\t" + line
+			// a macro that invokes itself would otherwise fill the screen
+			n := 0
+			for i + 1 < len(bt) && c.fset.Describe(bt[i + 1]) == line {
+				i++
+				n++
+			}
+			if n != 0 {
+				msg += fmt.Sprintf("\n\t(and %d more times)", n)
+			}
+		}
+	}
+	c.list.Add(p, msg)
+}
+
+// Len returns the number of errors added so far, including duplicates.
+func (c *ErrorCollector) Len() int {
+	return len(c.list)
+}
+
+// Errors returns the errors added so far, sorted by position, with duplicates removed and Limit applied.
+func (c *ErrorCollector) Errors() ErrorList {
+	list := make(ErrorList, len(c.list))
+	copy(list, c.list)
+	sort.Stable(list)
+	n := 0
+	for i, e := range list {
+		if i != 0 && e.Pos == list[n - 1].Pos && e.Msg == list[n - 1].Msg {
+			continue
+		}
+		list[n] = e
+		n++
+	}
+	list = list[:n]
+	if c.Limit > 0 && len(list) > c.Limit {
+		last := list[c.Limit - 1].Pos
+		list = append(list[:c.Limit], &Error{
+			Pos:		last,
+			Msg:		"too many errors",
+		})
+	}
+	return list
+}
+
+// Err returns an error equivalent to Errors, or nil if there are no errors.
+func (c *ErrorCollector) Err() error {
+	return c.Errors().Err()
+}
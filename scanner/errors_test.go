@@ -0,0 +1,89 @@
+// 19 october 2026
+package scanner
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/andlabs/a68/core"
+	"github.com/andlabs/a68/token"
+)
+
+type testEvalHandler struct {
+	*ErrorCollector
+}
+
+func (h *testEvalHandler) LookupName(name string) (val uint64, ok bool) {
+	return 0, false
+}
+
+func TestErrorCollector(t *testing.T) {
+	fset := token.NewFileSet()
+	f := fset.AddFile("test.s", -1, 32)
+	f.AddLine(16)
+	c := NewErrorCollector(fset)
+	h := &testEvalHandler{c}
+
+	c.Add(f.Position(f.Pos(20)), "scanner error")
+	e := core.NewExpr()
+	e.AddName(f.Pos(4), "missing")
+	e.AddName(f.Pos(4), "missing")
+	e.Add(f.Pos(2), core.ExprAdd)
+	e.Finish()
+	e.Evaluate(h)
+
+	want := []string{
+		"test.s:1:5: unknown names \"missing\"",
+		"test.s:2:5: scanner error",
+	}
+	list := c.Errors()
+	if len(list) != len(want) {
+		t.Fatalf("wrong number of errors: got %d (%v), want %d", len(list), list, len(want))
+	}
+	for i, e := range list {
+		if e.Error() != want[i] {
+			t.Errorf("error %d wrong: got %q, want %q", i, e.Error(), want[i])
+		}
+	}
+
+	c.Limit = 1
+	list = c.Errors()
+	if len(list) != 2 || list[1].Msg != "too many errors" {
+		t.Errorf("Limit not applied: got %v", list)
+	}
+}
+
+func TestErrorCollectorBacktrace(t *testing.T) {
+	fset := token.NewFileSet()
+	main := fset.AddFile("main.s", -1, 32)
+	main.AddLine(16)
+	inc := fset.AddFile("foo.inc", -1, 32)
+	inc.AddLine(8)
+	mac := fset.AddFile("foo.inc", -1, 8)
+	fset.SetOrigin(inc, token.Origin{
+		Kind:	token.Included,
+		Pos:		main.Pos(17),
+	})
+	fset.SetOrigin(mac, token.Origin{
+		Kind:	token.Expanded,
+		Pos:		inc.Pos(10),
+		Name:	"push",
+	})
+	c := NewErrorCollector(fset)
+	c.ReportError(main.Pos(2), fmt.Errorf("in main"))
+	c.ReportError(mac.Pos(3), fmt.Errorf("in macro"))
+
+	want := []string{
+		"foo.inc:1:4: in macro\n\tin expansion of macro push at foo.inc:2:3\n\tincluded from main.s:2:2",
+		"main.s:1:3: in main",
+	}
+	list := c.Errors()
+	if len(list) != len(want) {
+		t.Fatalf("wrong number of errors: got %d (%v), want %d", len(list), list, len(want))
+	}
+	for i, e := range list {
+		if e.Error() != want[i] {
+			t.Errorf("error %d wrong: got %q, want %q", i, e.Error(), want[i])
+		}
+	}
+}
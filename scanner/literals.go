@@ -0,0 +1,197 @@
+// 19 october 2026
+package scanner
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxCharBytes is the maximum number of bytes in a character literal; this is the size of a long on the 68000.
+const MaxCharBytes = 4
+
+// decodeQuoted decodes the body of a character or string literal (without its quotes) into the bytes it represents.
+// On error, off is the offset of the problem in body.
+func decodeQuoted(body string, quote byte) (b []byte, off int, err error) {
+	b = make([]byte, 0, len(body))
+	for i := 0; i < len(body); {
+		c := body[i]
+		if c == quote {
+			return nil, i, fmt.Errorf("unescaped %c in literal", quote)
+		}
+		if c != '\\' {
+			b = append(b, c)
+			i++
+			continue
+		}
+		start := i
+		i++
+		if i >= len(body) {
+			return nil, start, fmt.Errorf("escape sequence not terminated")
+		}
+		c = body[i]
+		i++
+		switch c {
+		case 'a':
+			b = append(b, '\a')
+		case 'b':
+			b = append(b, '\b')
+		case 'f':
+			b = append(b, '\f')
+		case 'n':
+			b = append(b, '\n')
+		case 'r':
+			b = append(b, '\r')
+		case 't':
+			b = append(b, '\t')
+		case 'v':
+			b = append(b, '\v')
+		case '0':
+			b = append(b, 0)
+		case '\\', '\'', '"':
+			b = append(b, c)
+		case 'x', 'u':
+			n := 2
+			if c == 'u' {
+				n = 4
+			}
+			if i + n > len(body) {
+				return nil, start, fmt.Errorf("escape sequence \\%c needs %d hexadecimal digits", c, n)
+			}
+			v, err := strconv.ParseUint(body[i:i + n], 16, 32)
+			if err != nil {
+				return nil, start, fmt.Errorf("escape sequence \\%c needs %d hexadecimal digits", c, n)
+			}
+			i += n
+			if c == 'x' {
+				b = append(b, byte(v))
+				break
+			}
+			if !utf8.ValidRune(rune(v)) {
+				return nil, start, fmt.Errorf("escape sequence is invalid Unicode code point U+%04X", v)
+			}
+			b = utf8.AppendRune(b, rune(v))
+		default:
+			return nil, start, fmt.Errorf("unknown escape sequence \\%c", c)
+		}
+	}
+	return b, 0, nil
+}
+
+// Unquote returns the bytes represented by the character or string literal lit, as returned by Scanner.Next.
+func Unquote(lit string) ([]byte, error) {
+	n := len(lit)
+	if n < 2 || (lit[0] != '\'' && lit[0] != '"') || lit[n - 1] != lit[0] {
+		return nil, fmt.Errorf("invalid quoted literal %s", lit)
+	}
+	b, _, err := decodeQuoted(lit[1:n - 1], lit[0])
+	return b, err
+}
+
+// CharValue returns the value of the character literal lit.
+// Multi-character literals are packed big-endian, as on the 68000; for instance, 'AB' is $4142.
+func CharValue(lit string) (uint64, error) {
+	b, err := Unquote(lit)
+	if err != nil {
+		return 0, err
+	}
+	if len(b) == 0 {
+		return 0, fmt.Errorf("empty character literal")
+	}
+	if len(b) > MaxCharBytes {
+		return 0, fmt.Errorf("character literal too long (%d bytes; max %d)", len(b), MaxCharBytes)
+	}
+	return packChar(b), nil
+}
+
+func packChar(b []byte) uint64 {
+	v := uint64(0)
+	for _, c := range b {
+		v = (v << 8) | uint64(c)
+	}
+	return v
+}
+
+// Integer literals are decimal (123), hexadecimal ($7B or 0x7B), binary (%1111011 or 0b1111011), or octal (0o173).
+// _ can separate digits, as in $FFFF_0000.
+// With MotorolaNumbers, @173 is octal, and a suffix can give the base instead of a prefix: 7Bh, 1111011b, 173o (or 173q), and 123d.
+
+var baseNames = map[int]string{
+	2:	"binary",
+	8:	"octal",
+	10:	"decimal",
+	16:	"hexadecimal",
+}
+
+// ParseInt returns the value of the integer literal lit, as scanned with mode.
+func ParseInt(lit string, mode Mode) (uint64, error) {
+	v, _, err := parseInt(lit, mode)
+	return v, err
+}
+
+// parseInt is ParseInt, but also returns the offset of any error in lit.
+func parseInt(lit string, mode Mode) (v uint64, off int, err error) {
+	base := 10
+	digits := lit
+	start := 0
+	motorola := mode & MotorolaNumbers != 0
+	lower := strings.ToLower(lit)
+	switch {
+	case motorola && strings.HasSuffix(lower, "h"):
+		// checked first because prefixed literals can never end in h, but 0b1h is hexadecimal
+		base, digits = 16, lit[:len(lit) - 1]
+	case lit[0] == '$':
+		base, start = 16, 1
+	case lit[0] == '%':
+		base, start = 2, 1
+	case motorola && lit[0] == '@':
+		base, start = 8, 1
+	case strings.HasPrefix(lower, "0x"):
+		base, start = 16, 2
+	case strings.HasPrefix(lower, "0b") && !(motorola && lower == "0b"):
+		base, start = 2, 2
+	case strings.HasPrefix(lower, "0o"):
+		base, start = 8, 2
+	case motorola && strings.HasSuffix(lower, "b"):
+		base, digits = 2, lit[:len(lit) - 1]
+	case motorola && (strings.HasSuffix(lower, "o") || strings.HasSuffix(lower, "q")):
+		base, digits = 8, lit[:len(lit) - 1]
+	case motorola && strings.HasSuffix(lower, "d"):
+		digits = lit[:len(lit) - 1]
+	}
+	digits = digits[start:]
+	name := baseNames[base]
+	if digits == "" {
+		return 0, 0, fmt.Errorf("%s literal %s has no digits", name, lit)
+	}
+
+	clean := make([]byte, 0, len(digits))
+	for i, r := range digits {
+		if r == '_' {
+			if i == 0 || i == len(digits) - 1 || digits[i - 1] == '_' {
+				return 0, start + i, fmt.Errorf("'_' must separate successive digits")
+			}
+			continue
+		}
+		d := 99
+		switch {
+		case r >= '0' && r <= '9':
+			d = int(r - '0')
+		case r >= 'a' && r <= 'z':
+			d = int(r - 'a') + 10
+		case r >= 'A' && r <= 'Z':
+			d = int(r - 'A') + 10
+		}
+		if d >= base {
+			return 0, start + i, fmt.Errorf("invalid digit %q in %s literal", r, name)
+		}
+		clean = append(clean, byte(r))
+	}
+	v, err = strconv.ParseUint(string(clean), base, 64)
+	if err != nil {
+		// we already validated the digits, so this can only be a range error
+		return 0, 0, fmt.Errorf("integer literal %s does not fit in 64 bits", lit)
+	}
+	return v, 0, nil
+}
@@ -3,6 +3,7 @@ package scanner
 
 import (
 	"fmt"
+	"bytes"
 	"unicode/utf8"
 
 	"github.com/andlabs/a68/token"
@@ -17,14 +18,21 @@ type reader struct {
 
 	errorCount	int
 	handler		ErrorHandler
+	lax			bool		// if set, don't report invalid UTF-8
 }
 
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
 func newReader(f *token.File, data []byte, handler ErrorHandler) *reader {
-	return &reader{
+	r := &reader{
 		f:			f,
 		b:			data,
 		handler:		handler,
 	}
+	if bytes.HasPrefix(data, utf8BOM) {
+		r.n = len(utf8BOM)		// skipped by the first read()
+	}
+	return r
 }
 
 func (r *reader) err(off int, format string, args ...interface{}) {
@@ -38,10 +46,20 @@ func (r *reader) read() (off int, ru rune) {
 	r.off += r.n
 	if r.off < len(r.b) {
 		r.r, r.n = utf8.DecodeRune(r.b[r.off:])
-		if r.r == utf8.RuneError && r.n == 1 {
-			r.err(r.off, "invalid byte 0x%X in UTF-8 stream", r.b[r.off])
+		switch {
+		case r.r == utf8.RuneError && r.n == 1:
+			if !r.lax {
+				r.err(r.off, "invalid byte 0x%X in UTF-8 stream", r.b[r.off])
+			}
 			// return the utf8.RuneError anyway, so as to not allow invalid UTF-8 mid-token
-		} else if r.r == '\n' {
+		case r.r == '\r':
+			// CRLF and a lone CR are both line endings; either way, the scanner only sees \n
+			if r.off + 1 < len(r.b) && r.b[r.off + 1] == '\n' {
+				r.n = 2
+			}
+			r.r = '\n'
+			r.f.AddLine(r.off + r.n)
+		case r.r == '\n':
 			r.f.AddLine(r.off + r.n)
 		}
 		return r.off, r.r
@@ -63,6 +81,11 @@ func (r *reader) peekbyteasrune() rune {
 	return rune(r.b[off])
 }
 
+// slice returns the source between the given offsets.
+func (r *reader) slice(start int, end int) string {
+	return string(r.b[start:end])
+}
+
 func (r *reader) pos(off int) token.Pos {
 	return r.f.Pos(off)
 }
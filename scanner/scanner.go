@@ -13,38 +13,178 @@ type result struct {
 	pos		token.Pos
 	tok		token.Token
 	lit		string
+	val		uint64
 }
 
+// Mode controls optional scanner behavior.
+type Mode uint
+const (
+	// ScanComments returns comments as COMMENT tokens instead of skipping them.
+	ScanComments Mode = 1 << iota
+	// FoldKeywords recognizes opcodes, registers, size suffixes, and other keywords regardless of case, as in MOVE.L D0,A1.
+	// The literal of each such keyword is returned in its canonical lowercase form.
+	FoldKeywords
+	// FoldSymbols also makes user symbols case-insensitive by returning the literal of every IDENT in lowercase.
+	// It implies FoldKeywords.
+	FoldSymbols
+	// MotorolaNumbers also accepts the integer literal forms of other 68000 assemblers: @17 for octal, and the suffixes in 0FFh, 1010b, 17o (or 17q), and 10d.
+	MotorolaNumbers
+)
+
+// Scanner produces tokens on demand.
+// Each call to Next runs the scanner's state functions until at least one token is ready; no goroutines are involved, so an abandoned Scanner is simply garbage collected.
 type Scanner struct {
 	r		*reader
-	res		chan result
+	state	statefunc		// nil once EOF has been sent
+	res		[]result		// tokens sent but not yet returned by Next, starting at resi
+	resi		int
+	eof		result
+	val		uint64		// of the last token returned by Next
 	errs		*ErrorList
+	mode	Mode
+
+	// for automatic statement terminators
+	last			token.Token		// the last token sent; TERM at the start of a statement
+	insertTerm	bool			// whether a newline (or EOF) here ends the statement
 }
 
-func NewScanner(f *token.File, data []byte) *Scanner {
+// NewScanner returns a Scanner that scans data, the contents of f.
+// Errors are collected for Errors; if handler is not nil, it is also called for each error as it happens.
+func NewScanner(f *token.File, data []byte, handler ErrorHandler, mode Mode) *Scanner {
 	if f.Size() != len(data) {
 		panic(fmt.Sprintf("size mismatch in NewScanner(): file size %d != data size %d", f.Size(), len(data)))
 	}
 	s := &Scanner{
-		res:		make(chan result),
+		state:	(*Scanner).next,
+		res:		make([]result, 0, 4),
 		errs:		&ErrorList{},
+		mode:	mode,
+		last:		token.TERM,
 	}
-	s.r = newReader(f, data, s.errs.Add)
-	go s.run()
+	s.r = newReader(f, data, func(pos token.Position, msg string) {
+		s.errs.Add(pos, msg)
+		if handler != nil {
+			handler(pos, msg)
+		}
+	})
+	s.r.read()		// get things going
 	return s
 }
 
+// Errors returns the errors encountered so far, in the order they happened.
+func (s *Scanner) Errors() ErrorList {
+	list := make(ErrorList, len(*s.errs))
+	copy(list, *s.errs)
+	return list
+}
+
+// ErrorCount returns the number of errors encountered so far.
+func (s *Scanner) ErrorCount() int {
+	return s.r.errorCount
+}
+
+// Next returns the next token. After the end of the file, Next returns EOF forever.
 func (s *Scanner) Next() (pos token.Pos, tok token.Token, lit string) {
-	r := <-s.res
+	for s.resi == len(s.res) {
+		if s.state == nil {
+			return s.eof.pos, s.eof.tok, s.eof.lit
+		}
+		s.res = s.res[:0]
+		s.resi = 0
+		s.state = s.state(s)
+	}
+	r := s.res[s.resi]
+	s.resi++
+	s.val = r.val
 	return r.pos, r.tok, r.lit
 }
 
+// Value returns the value of the last token returned by Next if it was an INT or CHAR, so that the literal does not need to be parsed again.
+// For any other token, Value returns 0.
+func (s *Scanner) Value() uint64 {
+	return s.val
+}
+
+// SkipLines passes over source that is not to be scanned as it is, such as the body of a macro.
+// It must only be called when the last token returned by Next was the TERM at the end of a line.
+// Starting with the line after that TERM, SkipLines passes each line, without its line ending, to stop, until stop returns true; scanning then resumes at the start of that line.
+// It returns the source of the lines that were passed over, line endings included, and the offset of the first of them.
+// If stop never returns true, SkipLines passes over the rest of the file and returns false.
+func (s *Scanner) SkipLines(stop func(line string) bool) (lines string, off int, ok bool) {
+	if s.state == nil {
+		// the TERM was the one at the end of the file
+		return "", s.r.off, false
+	}
+	if s.resi != len(s.res) || s.last != token.TERM {
+		panic("SkipLines() called somewhere other than the end of a line")
+	}
+	// invalid UTF-8 in these lines will be reported if and when they are scanned
+	s.r.lax = true
+	defer func() {
+		s.r.lax = false
+	}()
+	start, r := s.r.cur()
+	end := start
+	for r != -1 {
+		line := end
+		for r != -1 && r != '\n' {
+			end, r = s.r.read()
+		}
+		if stop(s.r.slice(line, end)) {
+			// back up to the start of the line; the newlines already read have already been added to the File
+			s.r.off = line
+			s.r.n = 0
+			s.r.read()
+			return s.r.slice(start, line), start, true
+		}
+		end, r = s.r.read()
+	}
+	return s.r.slice(start, end), start, false
+}
+
 func (s *Scanner) sendstr(off int, tok token.Token, lit string) {
-	s.res <- result{
+	s.sendValue(off, tok, lit, 0)
+}
+
+func (s *Scanner) sendValue(off int, tok token.Token, lit string, val uint64) {
+	r := result{
 		pos:		s.r.pos(off),
 		tok:		tok,
 		lit:		lit,
+		val:		val,
+	}
+	s.res = append(s.res, r)
+	if tok == token.EOF {
+		s.eof = r
 	}
+	if tok == token.COMMENT {
+		// comments are invisible to the rest of the statement
+		return
+	}
+	s.insertTerm = endsStatement(tok, s.last)
+	s.last = tok
+}
+
+// Statements are terminated by TERM, which is either written explicitly as :: or inserted automatically at the end of a line (and at the end of the file) if the last token on that line can end a statement.
+// So a line that ends with an operator, comma, or opening parenthesis continues onto the next line.
+// + and - are special: they end a statement if they are the first token in it (a nameless label) or if they follow a ) (postincrement, as in (a0)+).
+func endsStatement(tok token.Token, prev token.Token) bool {
+	switch {
+	case tok.IsLiteral(), tok.IsKeyword() && tok != token.MOD:
+		return true
+	case tok == token.RPAREN, tok == token.NEXT, tok == token.PREV:
+		return true
+	case tok == token.ILLEGAL:
+		// ILLEGAL always extends to the end of the statement; see resync()
+		return true
+	case tok == token.ADD || tok == token.SUB:
+		return prev == token.TERM || prev == token.RPAREN
+	}
+	return false
+}
+
+func (s *Scanner) sendTerm(off int) {
+	s.sendstr(off, token.TERM, "\n")
 }
 
 func (s *Scanner) send(off int, tok token.Token, lit []rune) {
@@ -53,131 +193,309 @@ func (s *Scanner) send(off int, tok token.Token, lit []rune) {
 
 type statefunc func(s *Scanner) statefunc
 
-func (s *Scanner) run() {
-	var sf statefunc = (*Scanner).next
-	s.r.read()		// get things going
-	for sf != nil {
-		sf(s)
-	}
-	close(s.res)
+// these map the first rune of an operator to how to scan it
+// multibyteTokens has to be filled in init() because its statefuncs refer back to it through (*Scanner).next
+var multibyteTokens map[rune]statefunc
+
+var singlebyteTokens = map[rune]token.Token{
+	'+':		token.ADD,
+	'-':		token.SUB,
+	'*':		token.MUL,
+	'/':		token.DIV,
+	'^':		token.BXOR,
+	'~':		token.CMPL,
+	',':		token.COMMA,
+	'#':		token.POUND,
+	'(':		token.LPAREN,
+	')':		token.RPAREN,
 }
 
-var multibyteTokens = map[rune]statefunc{
+func init() {
+	multibyteTokens = map[rune]statefunc{
+		'&':		multibyte(token.BAND, "&", token.LAND),
+		'|':		multibyte(token.BOR, "|", token.LOR),
+		'=':		multibyte(token.ASSIGN, "=", token.EQ),
+		'!':		multibyte(token.NOT, "=", token.NE),
+		'<':		multibyte(token.LT, "=<", token.LE, token.SHL),
+		'>':		multibyte(token.GT, "=>", token.GE, token.SHR),
+	}
 }
 
-var singlebyteTokens = map[rune]token.Token{
+// multibyte returns a statefunc that scans the longest operator starting with the current rune.
+// If the next rune is next[i], the operator is nextToks[i]; otherwise, it is tok.
+func multibyte(tok token.Token, next string, nextToks ...token.Token) statefunc {
+	return func(s *Scanner) statefunc {
+		off, r := s.r.cur()
+		t := tok
+		lit := []rune{r}
+		if i := strings.IndexRune(next, s.r.peekbyteasrune()); i != -1 {
+			_, r = s.r.read()
+			lit = append(lit, r)
+			t = nextToks[i]
+		}
+		s.send(off, t, lit)
+		s.r.read()
+		return (*Scanner).next
+	}
 }
 
 func (s *Scanner) next() statefunc {
 	off, r := s.r.cur()
 	if r == -1 {
+		if s.insertTerm {
+			s.sendTerm(off)
+		}
 		s.send(off, token.EOF, nil)
 		return nil					// stop scanning
 	}
+	if r == '\n' && s.insertTerm {
+		s.sendTerm(off)
+		s.r.read()
+		return (*Scanner).next
+	}
 	if r == ' ' || r == '\t' || r == '\n' || r == '\r' {
 		s.r.read()					// skip whitespace
 		return (*Scanner).next
 	}
+	if r == '%' && !strings.ContainsRune("01", s.r.peekbyteasrune()) {
+		// % is only ever a binary integer prefix
+		s.r.err(off, "%% must be followed by a binary digit (use .mod for the modulo operator)")
+		s.r.read()
+		return s.resync(off)
+	}
 	if (r >= '0' && r <= '9') || r == '$' || r == '%' {
 		return (*Scanner).nextInteger
 	}
+	if r == '@' && s.mode & MotorolaNumbers != 0 && unicode.IsDigit(s.r.peekbyteasrune()) {
+		return (*Scanner).nextInteger
+	}
+	if r == '@' {
+		return (*Scanner).nextLocal
+	}
+	if r == ':' {
+		return (*Scanner).nextColon
+	}
 	if r == '.' || r == '_' || unicode.IsLetter(r) {
 		return (*Scanner).nextIdentifier
 	}
-	// TODO characters and strings
+	if r == '\'' || r == '"' {
+		return (*Scanner).nextQuoted
+	}
+	if r == ';' {
+		return (*Scanner).nextLineComment
+	}
+	if r == '/' && s.r.peekbyteasrune() == '*' {
+		return (*Scanner).nextBlockComment
+	}
 	if f, ok := multibyteTokens[r]; ok {
 		return f
 	}
 	tok, ok := singlebyteTokens[r]
+	s.r.read()
 	if !ok {
-		tok = token.ILLEGAL
+		s.r.err(off, "invalid character %q", r)
+		return s.resync(off)
 	}
 	s.send(off, tok, []rune{r})
-	s.r.read()
+	return (*Scanner).next
+}
+
+// resync is called after an error in the input starting at off.
+// It skips to the end of the statement and sends everything skipped as a single ILLEGAL token, so that the parser sees one bad token instead of reporting more errors about the rest of the statement.
+// The end of the statement is the next newline or ::; a comment also stops skipping, so that it is scanned normally.
+func (s *Scanner) resync(off int) statefunc {
+	end, r := s.r.cur()
+	for r != -1 && r != '\n' && r != ';' {
+		next := s.r.peekbyteasrune()
+		if (r == ':' && next == ':') || (r == '/' && next == '*') {
+			break
+		}
+		end, r = s.r.read()
+	}
+	s.sendstr(off, token.ILLEGAL, strings.TrimRight(s.r.slice(off, end), " \t\r"))
 	return (*Scanner).next
 }
 
 func (s *Scanner) nextInteger() statefunc {
-	lit := make([]rune, 0, 16)
-	f := s.readDecimalInteger
+	// the literal is its first rune (a digit or prefix) and every letter, digit, and _ after it, so that mistakes like 12ab or $12G are reported as one bad literal
+	off, _ := s.r.cur()
+	end, r := s.r.read()
+	for r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+		end, r = s.r.read()
+	}
+	lit := s.r.slice(off, end)
+	v, eoff, err := parseInt(lit, s.mode)
+	if err != nil {
+		s.r.err(off + eoff, "%v", err)
+		return s.resync(off)
+	}
+	s.sendValue(off, token.INT, lit, v)
+	return (*Scanner).next
+}
 
+func (s *Scanner) nextIdentifier() statefunc {
 	off, r := s.r.cur()
-	lit = append(lit, r)
-	if r == '$' {
-		f = s.readHexInteger
-		goto read
-	}
-	if r == '%' {
-		f = s.readBinaryInteger
-		goto read
+	end := off
+	for r != -1 {
+		if r != '.' && r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		end, r = s.r.read()
 	}
-	if r != '0' {
-		goto read
+	strlit := s.r.slice(off, end)
+	tok := token.IDENT
+	if s.mode & (FoldKeywords | FoldSymbols) != 0 {
+		tok, strlit = token.LookupFold(strlit)
+		if tok == token.IDENT && s.mode & FoldSymbols != 0 {
+			strlit = strings.ToLower(strlit)
+		}
+	} else {
+		tok = token.Lookup(strlit)
 	}
-	r = s.r.peekbyteasrune()
-	if r == -1 {		// the last token of the file is a single 0
-		goto send
+	if tok == token.IDENT && strlit[0] == '.' {
+		s.r.err(off, "unknown keyword %q", strlit)
+		return s.resync(off)
 	}
-	if r == 'x' || r == 'X' {
-		s.r.read()
-		lit = append(lit, r)
-		f = s.readHexInteger
-		goto read
+	s.sendstr(off, tok, strlit)
+	return (*Scanner).next
+}
+
+func (s *Scanner) nextQuoted() statefunc {
+	off, quote := s.r.cur()
+	tok, what := token.CHAR, "character"
+	if quote == '"' {
+		tok, what = token.STRING, "string"
 	}
-	if r == 'b' || r == 'B' {
-		s.r.read()
+	lit := make([]rune, 0, 16)
+	lit = append(lit, quote)
+	escaped := false
+	for {
+		_, r := s.r.read()
+		if r == -1 || r == '\n' {
+			s.r.err(off, "%s literal not terminated", what)
+			return s.resync(off)
+		}
 		lit = append(lit, r)
-		f = s.readBinaryInteger
-		goto read
+		if r == quote && !escaped {
+			break
+		}
+		escaped = !escaped && r == '\\'
 	}
+	s.r.read()
 
-read:
-	lit = append(lit, f()...)
-send:
-	s.send(off, token.INT, lit)
-	return (*Scanner).next
+	strlit := string(lit)
+	b, boff, err := decodeQuoted(strlit[1:len(strlit) - 1], byte(quote))
+	switch {
+	case err != nil:
+		s.r.err(off + 1 + boff, "%v", err)
+	case tok == token.CHAR && len(b) == 0:
+		s.r.err(off, "empty character literal")
+	case tok == token.CHAR && len(b) > MaxCharBytes:
+		s.r.err(off, "character literal too long (%d bytes; max %d)", len(b), MaxCharBytes)
+	default:
+		s.sendValue(off, tok, strlit, packChar(b))
+		return (*Scanner).next
+	}
+	return s.resync(off)
 }
 
-func (s *Scanner) readBinaryInteger() []rune {
-	return s.readStringOf("01")
-}
+// Comments are either ; to the end of the line, or /* to the next */.
+// A block comment that spans lines acts as a newline for the purposes of automatic statement terminators.
 
-func (s *Scanner) readDecimalInteger() []rune {
-	return s.readStringOf("0123456789")
+func (s *Scanner) sendComment(off int, lit string) {
+	if s.mode & ScanComments != 0 {
+		s.sendstr(off, token.COMMENT, lit)
+	}
 }
 
-func (s *Scanner) readHexInteger() []rune {
-	return s.readStringOf("0123456789ABCDEFabcdef")
+// Comments can contain bytes that are not valid UTF-8 without errors, since so much existing source has comments in other encodings.
+
+func (s *Scanner) nextLineComment() statefunc {
+	off, r := s.r.cur()
+	end := off
+	s.r.lax = true
+	for r != -1 && r != '\n' {
+		end, r = s.r.read()
+	}
+	s.r.lax = false
+	if s.mode & ScanComments != 0 {
+		s.sendstr(off, token.COMMENT, s.r.slice(off, end))
+	}
+	return (*Scanner).next
 }
 
-func (s *Scanner) readStringOf(runes string) (lit []rune) {
-	lit = make([]rune, 0, 8)
+func (s *Scanner) nextBlockComment() statefunc {
+	off, _ := s.r.cur()
+	s.r.lax = true
+	s.r.read()		// skip the *
+	newline := false
 	for {
-		_, r := s.r.read()
-		if r == -1 || !strings.ContainsRune(runes, r) {
-			break
+		end, r := s.r.read()
+		if r == -1 {
+			s.r.lax = false
+			s.r.err(off, "comment not terminated")
+			s.sendComment(off, s.r.slice(off, end))
+			return (*Scanner).next
+		}
+		if r == '\n' {
+			newline = true
+		}
+		if r == '*' && s.r.peekbyteasrune() == '/' {
+			s.r.read()
+			s.r.lax = false
+			end, _ = s.r.read()
+			s.sendComment(off, s.r.slice(off, end))
+			if newline && s.insertTerm {
+				s.sendTerm(end)
+			}
+			return (*Scanner).next
 		}
-		lit = append(lit, r)
 	}
-	return lit
 }
 
-func (s *Scanner) nextIdentifier() statefunc {
-	lit := make([]rune, 0, 16)
-	off, r := s.r.cur()
-	for r != -1 {
-		if r != '.' && r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
-			break
-		}
-		lit = append(lit, r)
-		_, r = s.r.read()
+// Local labels are written @name; they belong to the closest preceding global label.
+func (s *Scanner) nextLocal() statefunc {
+	off, _ := s.r.cur()
+	end, r := s.r.read()
+	if r != '_' && !unicode.IsLetter(r) {
+		s.r.err(off, "@ must be followed by the name of a local label")
+		return s.resync(off)
 	}
-	strlit := string(lit)
-	tok := token.Lookup(strlit)
-	if tok == token.IDENT && lit[0] == '.' {
-		s.r.err(off, "unknown keyword %q", strlit)
-		return (*Scanner).next
+	for r == '.' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+		end, r = s.r.read()
+	}
+	lit := s.r.slice(off, end)
+	if s.mode & FoldSymbols != 0 {
+		lit = strings.ToLower(lit)
+	}
+	s.sendstr(off, token.AT, lit)
+	return (*Scanner).next
+}
+
+// Nameless labels are defined by a + or - at the start of a statement (which the scanner returns as ADD or SUB), and referred to by :+ (the next + label), :++ (the one after that), :- (the previous - label), :-- (the one before that), and so on.
+// This means that a:+b and a:-b are always read as a :+ b and a :- b; use spaces if you want a : +b or a : -b.
+// :: is a statement terminator, and : on its own ends a label.
+func (s *Scanner) nextColon() statefunc {
+	off, _ := s.r.cur()
+	switch c := s.r.peekbyteasrune(); c {
+	case ':':
+		s.r.read()
+		s.r.read()
+		s.sendstr(off, token.TERM, "::")
+	case '+', '-':
+		tok := token.NEXT
+		if c == '-' {
+			tok = token.PREV
+		}
+		s.r.read()
+		end, r := s.r.cur()
+		for r == c {
+			end, r = s.r.read()
+		}
+		s.sendstr(off, tok, s.r.slice(off, end))
+	default:
+		s.r.read()
+		s.sendstr(off, token.COLON, ":")
 	}
-	s.sendstr(off, tok, strlit)
 	return (*Scanner).next
 }
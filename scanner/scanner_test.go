@@ -0,0 +1,440 @@
+// 19 october 2026
+package scanner
+
+import (
+	"testing"
+
+	"github.com/andlabs/a68/token"
+)
+
+type testToken struct {
+	tok		token.Token
+	lit		string
+}
+
+func scanAll(t *testing.T, src string, mode Mode) []testToken {
+	fset := token.NewFileSet()
+	f := fset.AddFile("test.s", -1, len(src))
+	s := NewScanner(f, []byte(src), nil, mode)
+	var toks []testToken
+	for {
+		_, tok, lit := s.Next()
+		if tok == token.EOF {
+			break
+		}
+		toks = append(toks, testToken{tok, lit})
+	}
+	return toks
+}
+
+func testScan(t *testing.T, src string, want []testToken) {
+	testScanMode(t, src, 0, want)
+}
+
+func testScanMode(t *testing.T, src string, mode Mode, want []testToken) {
+	got := scanAll(t, src, mode)
+	if len(got) != len(want) {
+		t.Fatalf("wrong number of tokens scanning %q: got %v, want %v", src, got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("token %d of %q wrong: got %v %q, want %v %q", i, src, got[i].tok, got[i].lit, want[i].tok, want[i].lit)
+		}
+	}
+}
+
+func TestScanOperators(t *testing.T) {
+	testScan(t, "+ - * / & | ^ << >> ~ == != < <= > >= && || ! = , : :+ :- # ( )", []testToken{
+		{token.ADD, "+"}, {token.SUB, "-"}, {token.MUL, "*"}, {token.DIV, "/"},
+		{token.BAND, "&"}, {token.BOR, "|"}, {token.BXOR, "^"}, {token.SHL, "<<"}, {token.SHR, ">>"}, {token.CMPL, "~"},
+		{token.EQ, "=="}, {token.NE, "!="}, {token.LT, "<"}, {token.LE, "<="}, {token.GT, ">"}, {token.GE, ">="},
+		{token.LAND, "&&"}, {token.LOR, "||"}, {token.NOT, "!"}, {token.ASSIGN, "="},
+		{token.COMMA, ","}, {token.COLON, ":"}, {token.NEXT, ":+"}, {token.PREV, ":-"},
+		{token.POUND, "#"}, {token.LPAREN, "("}, {token.RPAREN, ")"},
+		{token.TERM, "\n"},
+	})
+	// longest match, without spaces
+	testScan(t, "a<<=b&&&c:-1", []testToken{
+		{token.IDENT, "a"}, {token.SHL, "<<"}, {token.ASSIGN, "="}, {token.IDENT, "b"},
+		{token.LAND, "&&"}, {token.BAND, "&"}, {token.IDENT, "c"}, {token.PREV, ":-"}, {token.INT, "1"},
+		{token.TERM, "\n"},
+	})
+}
+
+func TestScanPercent(t *testing.T) {
+	testScan(t, "%0101 .mod %", []testToken{
+		{token.INT, "%0101"}, {token.MOD, ".mod"}, {token.ILLEGAL, "%"}, {token.TERM, "\n"},
+	})
+}
+
+func TestScanTerminators(t *testing.T) {
+	for _, tc := range []struct {
+		name	string
+		src		string
+		want	[]testToken
+	}{{
+		name:	"Explicit",
+		src:		"a :: b::c",
+		want:	[]testToken{
+			{token.IDENT, "a"}, {token.TERM, "::"}, {token.IDENT, "b"}, {token.TERM, "::"},
+			{token.IDENT, "c"}, {token.TERM, "\n"},
+		},
+	}, {
+		name:	"NoDoubleTerminator",
+		src:		"a ::\n\n\nb\n",
+		want:	[]testToken{
+			{token.IDENT, "a"}, {token.TERM, "::"}, {token.IDENT, "b"}, {token.TERM, "\n"},
+		},
+	}, {
+		name:	"ContinuedAfterOperator",
+		src:		"a = 1 +\n\t2 *\n(3\n)\n",
+		want:	[]testToken{
+			{token.IDENT, "a"}, {token.ASSIGN, "="}, {token.INT, "1"}, {token.ADD, "+"},
+			{token.INT, "2"}, {token.MUL, "*"}, {token.LPAREN, "("}, {token.INT, "3"}, {token.TERM, "\n"},
+			{token.RPAREN, ")"}, {token.TERM, "\n"},
+		},
+	}, {
+		name:	"ContinuedAfterComma",
+		src:		"a 1,\n2\n",
+		want:	[]testToken{
+			{token.IDENT, "a"}, {token.INT, "1"}, {token.COMMA, ","}, {token.INT, "2"}, {token.TERM, "\n"},
+		},
+	}, {
+		name:	"Postincrement",
+		src:		"a b,(a0)+\nc (a0)+,b\n",
+		want:	[]testToken{
+			{token.IDENT, "a"}, {token.IDENT, "b"}, {token.COMMA, ","},
+			{token.LPAREN, "("}, {token.ADDRREG, "a0"}, {token.RPAREN, ")"}, {token.ADD, "+"}, {token.TERM, "\n"},
+			{token.IDENT, "c"}, {token.LPAREN, "("}, {token.ADDRREG, "a0"}, {token.RPAREN, ")"}, {token.ADD, "+"},
+			{token.COMMA, ","}, {token.IDENT, "b"}, {token.TERM, "\n"},
+		},
+	}, {
+		name:	"NamelessLabels",
+		src:		"+\n-\n-\ta :-\n",
+		want:	[]testToken{
+			{token.ADD, "+"}, {token.TERM, "\n"},
+			{token.SUB, "-"}, {token.TERM, "\n"},
+			{token.SUB, "-"}, {token.IDENT, "a"}, {token.PREV, ":-"}, {token.TERM, "\n"},
+		},
+	}, {
+		name:	"LabelContinues",
+		src:		"a:\n\tb\n",
+		want:	[]testToken{
+			{token.IDENT, "a"}, {token.COLON, ":"}, {token.IDENT, "b"}, {token.TERM, "\n"},
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			testScan(t, tc.src, tc.want)
+		})
+	}
+}
+
+func TestScanQuoted(t *testing.T) {
+	testScan(t, `'A' 'ABCD' "a\"b\n" '\x7F\\' "é"`, []testToken{
+		{token.CHAR, `'A'`}, {token.CHAR, `'ABCD'`}, {token.STRING, `"a\"b\n"`},
+		{token.CHAR, `'\x7F\\'`}, {token.STRING, `"é"`}, {token.TERM, "\n"},
+	})
+	testScan(t, "'ABCDE' ''\n\"abc\n'\\q'", []testToken{
+		{token.ILLEGAL, `'ABCDE' ''`}, {token.TERM, "\n"},
+		{token.ILLEGAL, `"abc`}, {token.TERM, "\n"},
+		{token.ILLEGAL, `'\q'`}, {token.TERM, "\n"},
+	})
+}
+
+func TestCharValue(t *testing.T) {
+	for _, tc := range []struct {
+		lit		string
+		want	uint64
+	}{
+		{`'A'`, 0x41},
+		{`'ABCD'`, 0x41424344},
+		{`'\0\n'`, 0x000A},
+		{`'\xFF\''`, 0xFF27},
+		{`'é'`, 0xC3A9},
+	} {
+		got, err := CharValue(tc.lit)
+		if err != nil {
+			t.Errorf("CharValue(%s) failed: %v", tc.lit, err)
+		} else if got != tc.want {
+			t.Errorf("CharValue(%s) wrong: got 0x%X, want 0x%X", tc.lit, got, tc.want)
+		}
+	}
+	for _, lit := range []string{`''`, `'ABCDE'`, `'\x4'`} {
+		if _, err := CharValue(lit); err == nil {
+			t.Errorf("CharValue(%s) succeeded; want error", lit)
+		}
+	}
+}
+
+func TestScanComments(t *testing.T) {
+	src := "a ; comment\n/* block */ b /* multiple\nlines */ c,/*\n*/d ;"
+	testScan(t, src, []testToken{
+		{token.IDENT, "a"}, {token.TERM, "\n"},
+		{token.IDENT, "b"}, {token.TERM, "\n"},
+		{token.IDENT, "c"}, {token.COMMA, ","}, {token.IDENT, "d"}, {token.TERM, "\n"},
+	})
+	testScanMode(t, src, ScanComments, []testToken{
+		{token.IDENT, "a"}, {token.COMMENT, "; comment"}, {token.TERM, "\n"},
+		{token.COMMENT, "/* block */"}, {token.IDENT, "b"}, {token.COMMENT, "/* multiple\nlines */"}, {token.TERM, "\n"},
+		{token.IDENT, "c"}, {token.COMMA, ","}, {token.COMMENT, "/*\n*/"}, {token.IDENT, "d"}, {token.COMMENT, ";"}, {token.TERM, "\n"},
+	})
+	testScanMode(t, "a /* not terminated", ScanComments, []testToken{
+		{token.IDENT, "a"}, {token.COMMENT, "/* not terminated"}, {token.TERM, "\n"},
+	})
+}
+
+func TestScanPastEOF(t *testing.T) {
+	fset := token.NewFileSet()
+	f := fset.AddFile("test.s", -1, 1)
+	s := NewScanner(f, []byte("a"), nil, 0)
+	for i, want := range []token.Token{token.IDENT, token.TERM, token.EOF, token.EOF, token.EOF} {
+		_, tok, _ := s.Next()
+		if tok != want {
+			t.Errorf("token %d wrong: got %v, want %v", i, tok, want)
+		}
+	}
+}
+
+// benchSource is about 4MB of typical source.
+var benchSource = func() []byte {
+	const block = `; copy a block of memory
+copy:	move.l	d0,-(sp)
+	lea	(src_buffer).l,a0	/* source */
+	lea	$FF0000,a1
+	moveq	#%0111,d0
+-	move.w	(a0)+,(a1)+
+	dbf	d0,:-
+	cmpi.b	#'A',d1
+	beq	:+
+	addi.l	#(128 * 4) << 2 | $F,d2
++	move.l	(sp)+,d0 :: rts
+`
+	b := make([]byte, 0, 4 << 20)
+	for len(b) < 4 << 20 {
+		b = append(b, block...)
+	}
+	return b
+}()
+
+func BenchmarkScan(b *testing.B) {
+	b.SetBytes(int64(len(benchSource)))
+	for i := 0; i < b.N; i++ {
+		fset := token.NewFileSet()
+		f := fset.AddFile("bench.s", -1, len(benchSource))
+		s := NewScanner(f, benchSource, nil, 0)
+		for {
+			_, tok, _ := s.Next()
+			if tok == token.EOF {
+				break
+			}
+		}
+	}
+}
+
+func TestScanErrorRecovery(t *testing.T) {
+	src := "a `b c$ 'd\ne ?f :: g\n.bogus h ; comment\n"
+	var handled []string
+	fset := token.NewFileSet()
+	f := fset.AddFile("test.s", -1, len(src))
+	s := NewScanner(f, []byte(src), func(pos token.Position, msg string) {
+		handled = append(handled, pos.String() + ": " + msg)
+	}, ScanComments)
+	want := []testToken{
+		{token.IDENT, "a"}, {token.ILLEGAL, "`b c$ 'd"}, {token.TERM, "\n"},
+		{token.IDENT, "e"}, {token.ILLEGAL, "?f"}, {token.TERM, "::"}, {token.IDENT, "g"}, {token.TERM, "\n"},
+		{token.ILLEGAL, ".bogus h"}, {token.COMMENT, "; comment"}, {token.TERM, "\n"},
+	}
+	for i, w := range want {
+		_, tok, lit := s.Next()
+		if tok != w.tok || lit != w.lit {
+			t.Errorf("token %d wrong: got %v %q, want %v %q", i, tok, lit, w.tok, w.lit)
+		}
+	}
+	if _, tok, _ := s.Next(); tok != token.EOF {
+		t.Errorf("expected EOF, got %v", tok)
+	}
+	wantErrs := []string{
+		"test.s:1:3: invalid character '`'",
+		"test.s:2:3: invalid character '?'",
+		"test.s:3:1: unknown keyword \".bogus\"",
+	}
+	if s.ErrorCount() != len(wantErrs) || len(s.Errors()) != len(wantErrs) || len(handled) != len(wantErrs) {
+		t.Fatalf("wrong number of errors: ErrorCount() %d, Errors() %v, handler %v, want %v", s.ErrorCount(), s.Errors(), handled, wantErrs)
+	}
+	for i, e := range s.Errors() {
+		if e.Error() != wantErrs[i] || handled[i] != wantErrs[i] {
+			t.Errorf("error %d wrong: got %q (handler %q), want %q", i, e.Error(), handled[i], wantErrs[i])
+		}
+	}
+}
+
+func TestScanFold(t *testing.T) {
+	src := "Label D0.W,SP (PC) Usp ccr .L"
+	testScan(t, src, []testToken{
+		{token.IDENT, "Label"}, {token.IDENT, "D0.W"}, {token.COMMA, ","}, {token.IDENT, "SP"},
+		{token.LPAREN, "("}, {token.IDENT, "PC"}, {token.RPAREN, ")"}, {token.IDENT, "Usp"}, {token.CCR, "ccr"},
+		{token.ILLEGAL, ".L"}, {token.TERM, "\n"},
+	})
+	testScanMode(t, src, FoldKeywords, []testToken{
+		{token.IDENT, "Label"}, {token.DATAREG_W, "d0.w"}, {token.COMMA, ","}, {token.ADDRREG, "sp"},
+		{token.LPAREN, "("}, {token.PC, "pc"}, {token.RPAREN, ")"}, {token.USP, "usp"}, {token.CCR, "ccr"},
+		{token.DOT_L, ".l"}, {token.TERM, "\n"},
+	})
+	testScanMode(t, src, FoldSymbols, []testToken{
+		{token.IDENT, "label"}, {token.DATAREG_W, "d0.w"}, {token.COMMA, ","}, {token.ADDRREG, "sp"},
+		{token.LPAREN, "("}, {token.PC, "pc"}, {token.RPAREN, ")"}, {token.USP, "usp"}, {token.CCR, "ccr"},
+		{token.DOT_L, ".l"}, {token.TERM, "\n"},
+	})
+}
+
+func TestParseInt(t *testing.T) {
+	for _, tc := range []struct {
+		lit		string
+		mode	Mode
+		want	uint64
+		err		bool
+	}{
+		{lit: "0", want: 0},
+		{lit: "1234", want: 1234},
+		{lit: "$FFFF_0000", want: 0xFFFF0000},
+		{lit: "0x7b", want: 0x7B},
+		{lit: "%1010", want: 10},
+		{lit: "0b1_0", want: 2},
+		{lit: "0o17", want: 017},
+		{lit: "$FFFFFFFFFFFFFFFF", want: 0xFFFFFFFFFFFFFFFF},
+		{lit: "$", err: true},
+		{lit: "0x", err: true},
+		{lit: "$12G", err: true},
+		{lit: "12ab", err: true},
+		{lit: "1__0", err: true},
+		{lit: "10_", err: true},
+		{lit: "$_10", err: true},
+		{lit: "$1_0000_0000_0000_0000", err: true},
+		{lit: "18446744073709551616", err: true},
+		{lit: "0FFh", err: true},
+		{lit: "0FFh", mode: MotorolaNumbers, want: 0xFF},
+		{lit: "0b1h", mode: MotorolaNumbers, want: 0xB1},
+		{lit: "1010b", mode: MotorolaNumbers, want: 10},
+		{lit: "0b", mode: MotorolaNumbers, want: 0},
+		{lit: "0b11", mode: MotorolaNumbers, want: 3},
+		{lit: "17o", mode: MotorolaNumbers, want: 017},
+		{lit: "17Q", mode: MotorolaNumbers, want: 017},
+		{lit: "@17", mode: MotorolaNumbers, want: 017},
+		{lit: "10d", mode: MotorolaNumbers, want: 10},
+		{lit: "h", mode: MotorolaNumbers, err: true},
+		{lit: "19o", mode: MotorolaNumbers, err: true},
+	} {
+		got, err := ParseInt(tc.lit, tc.mode)
+		if tc.err {
+			if err == nil {
+				t.Errorf("ParseInt(%q, %v) succeeded with 0x%X; want error", tc.lit, tc.mode, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseInt(%q, %v) failed: %v", tc.lit, tc.mode, err)
+		} else if got != tc.want {
+			t.Errorf("ParseInt(%q, %v) wrong: got 0x%X, want 0x%X", tc.lit, tc.mode, got, tc.want)
+		}
+	}
+}
+
+func TestScanIntegerValues(t *testing.T) {
+	src := "$FF00.w 12ab,d0 'AB' 0x"
+	fset := token.NewFileSet()
+	f := fset.AddFile("test.s", -1, len(src))
+	s := NewScanner(f, []byte(src), nil, 0)
+	for i, w := range []struct {
+		tok		token.Token
+		lit		string
+		val		uint64
+	}{
+		{token.INT, "$FF00", 0xFF00},
+		{token.DOT_W, ".w", 0},
+		{token.ILLEGAL, "12ab,d0 'AB' 0x", 0},
+		{token.TERM, "\n", 0},
+		{token.EOF, "", 0},
+	} {
+		_, tok, lit := s.Next()
+		if tok != w.tok || lit != w.lit || s.Value() != w.val {
+			t.Errorf("token %d wrong: got %v %q 0x%X, want %v %q 0x%X", i, tok, lit, s.Value(), w.tok, w.lit, w.val)
+		}
+	}
+	if want := "test.s:1:11: invalid digit 'a' in decimal literal"; len(s.Errors()) != 1 || s.Errors()[0].Error() != want {
+		t.Errorf("wrong errors: got %v, want %q", s.Errors(), want)
+	}
+	testScan(t, "'AB'", []testToken{{token.CHAR, "'AB'"}, {token.TERM, "\n"}})
+}
+
+func TestScanLabels(t *testing.T) {
+	testScan(t, "global: @loop: bra @loop\n-\n+ bra :- :: bne :+++\ndbf d0,:--\n@ x\n", []testToken{
+		{token.IDENT, "global"}, {token.COLON, ":"}, {token.AT, "@loop"}, {token.COLON, ":"},
+		{token.OPCODE, "bra"}, {token.AT, "@loop"}, {token.TERM, "\n"},
+		{token.SUB, "-"}, {token.TERM, "\n"},
+		{token.ADD, "+"}, {token.OPCODE, "bra"}, {token.PREV, ":-"}, {token.TERM, "::"},
+		{token.OPCODE, "bne"}, {token.NEXT, ":+++"}, {token.TERM, "\n"},
+		{token.OPCODE, "dbf"}, {token.DATAREG, "d0"}, {token.COMMA, ","}, {token.PREV, ":--"}, {token.TERM, "\n"},
+		{token.ILLEGAL, "@ x"}, {token.TERM, "\n"},
+	})
+	testScanMode(t, "@Loop", FoldSymbols, []testToken{{token.AT, "@loop"}, {token.TERM, "\n"}})
+}
+
+func TestScanLineEndings(t *testing.T) {
+	src := "\xEF\xBB\xBFa\r\nb ; caf\xE9\rc 'x\r\n"
+	fset := token.NewFileSet()
+	f := fset.AddFile("test.s", -1, len(src))
+	s := NewScanner(f, []byte(src), nil, ScanComments)
+	for i, w := range []struct {
+		tok		token.Token
+		lit		string
+		pos		string
+	}{
+		{token.IDENT, "a", "test.s:1:4"},
+		{token.TERM, "\n", "test.s:1:5"},
+		{token.IDENT, "b", "test.s:2:1"},
+		{token.COMMENT, "; caf\xE9", "test.s:2:3"},
+		{token.TERM, "\n", "test.s:2:9"},
+		{token.IDENT, "c", "test.s:3:1"},
+		{token.ILLEGAL, "'x", "test.s:3:3"},
+		{token.TERM, "\n", "test.s:3:5"},
+		{token.EOF, "", "test.s:3:7"},
+	} {
+		pos, tok, lit := s.Next()
+		if tok != w.tok || lit != w.lit || fset.Position(pos).String() != w.pos {
+			t.Errorf("token %d wrong: got %v %q at %v, want %v %q at %v", i, tok, lit, fset.Position(pos), w.tok, w.lit, w.pos)
+		}
+	}
+	if len(s.Errors()) != 1 {
+		t.Errorf("wrong errors: got %v, want only the unterminated character literal", s.Errors())
+	}
+}
+
+func TestTranscode(t *testing.T) {
+	for _, tc := range []struct {
+		name	string
+		src		string
+		want	string
+	}{
+		{"", "abc", "abc"},
+		{"UTF-8", "caf\xC3\xA9", "café"},
+		{"latin1", "caf\xE9", "café"},
+		{"windows-1252", "\x93caf\xE9\x94", "“café”"},
+		{"", "\xFF\xFEa\x00b\x00", "ab"},
+		{"utf_16be", "\x00a\xD8\x3D\xDE\x00", "a\U0001F600"},
+		{"UTF-16", "\xFF\xFE\xE9\x00", "é"},
+	} {
+		got, err := Transcode([]byte(tc.src), tc.name)
+		if err != nil {
+			t.Errorf("Transcode(%q, %q) failed: %v", tc.src, tc.name, err)
+		} else if string(got) != tc.want {
+			t.Errorf("Transcode(%q, %q) wrong: got %q, want %q", tc.src, tc.name, got, tc.want)
+		}
+	}
+	if _, err := Transcode([]byte("abc"), "not-an-encoding"); err == nil {
+		t.Errorf("Transcode() with unknown encoding succeeded; want error")
+	}
+	_, err := Transcode([]byte("\xFF\xFEa"), "")
+	if want := "error converting source from UTF-16: odd number of bytes"; err == nil || err.Error() != want {
+		t.Errorf("Transcode() of odd-length UTF-16 wrong error: got %v, want %s", err, want)
+	}
+}
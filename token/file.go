@@ -2,6 +2,7 @@
 package token
 
 import (
+	"fmt"
 	gotoken "go/token"
 )
 
@@ -12,7 +13,70 @@ type Position = gotoken.Position
 
 type File = gotoken.File
 
-type FileSet = gotoken.FileSet
+// FileSet is a go/token FileSet that also remembers where each of its files came from, such as the .include that read it, so that diagnostics can show how a position was reached.
+type FileSet struct {
+	*gotoken.FileSet
+	origins	map[*File]Origin
+}
+
 func NewFileSet() *FileSet {
-	return gotoken.NewFileSet()
+	return &FileSet{
+		FileSet:	gotoken.NewFileSet(),
+		origins:	make(map[*File]Origin),
+	}
+}
+
+// OriginKind says how a file came to be assembled.
+type OriginKind int
+const (
+	Included OriginKind = iota + 1	// read by .include
+	Expanded					// the expansion of a macro
+)
+
+// Origin is where a file came from.
+type Origin struct {
+	Kind	OriginKind
+	Pos		Pos			// of the .include directive or the macro invocation
+	Name	string		// for Expanded, the name of the macro
+}
+
+// SetOrigin records where f came from.
+func (s *FileSet) SetOrigin(f *File, o Origin) {
+	s.origins[f] = o
+}
+
+// Origin returns where f came from, if it came from anywhere other than the command line.
+func (s *FileSet) Origin(f *File) (o Origin, ok bool) {
+	o, ok = s.origins[f]
+	return o, ok
+}
+
+// Backtrace returns the origin of the file that pos is in, then the origin of the file that that origin is in, and so on.
+// It returns nil for a position in a file that did not come from anywhere.
+func (s *FileSet) Backtrace(pos Pos) []Origin {
+	var bt []Origin
+	for pos.IsValid() {
+		f := s.File(pos)
+		if f == nil {
+			break
+		}
+		o, ok := s.origins[f]
+		if !ok {
+			break
+		}
+		bt = append(bt, o)
+		pos = o.Pos
+	}
+	return bt
+}
+
+// Describe returns a line of a backtrace for o, such as "included from main.s:40:2".
+func (s *FileSet) Describe(o Origin) string {
+	switch o.Kind {
+	case Included:
+		return fmt.Sprintf("included from %v", s.Position(o.Pos))
+	case Expanded:
+		return fmt.Sprintf("in expansion of macro %s at %v", o.Name, s.Position(o.Pos))
+	}
+	return fmt.Sprintf("from %v", s.Position(o.Pos))
 }
@@ -4,6 +4,7 @@ package token
 import (
 	gotoken "go/token"
 	"strconv"
+	"strings"
 
 	"github.com/andlabs/a68/core"
 )
@@ -22,6 +23,7 @@ const (
 	literalBegin
 	// Literal tokens.
 	IDENT
+	AT		// @name (a local label; the literal includes the @)
 	INT
 	CHAR
 	STRING
@@ -53,13 +55,14 @@ const (
 	LOR		// ||
 	NOT		// !
 
+	ASSIGN	// =
+
 	COMMA	// ,
-	SEMI		// ;
 	COLON	// :
+	TERM	// :: (statement terminator; also inserted automatically at the end of a line)
 
-	AT		// @ (denotes local labels)
-	NEXT	// :+ (reference to next nameless label in scope)
-	PREV		// :- (reference to previous nameless label in scope)
+	NEXT	// :+ (reference to next nameless label in scope; :++ for the one after that, and so on)
+	PREV		// :- (reference to previous nameless label in scope; :-- for the one before that, and so on)
 
 	POUND	// #
 
@@ -77,6 +80,7 @@ const (
 	ADDRREG_W	// a0.w .. a7.w, sp.w
 	DATAREG_L	// d0.l .. d7.l
 	ADDRREG_L	// a0.l .. a7.l, sp.l
+	DIRECTIVE	// .org, .include, and everything else in Directives
 	keywordClassEnd
 
 	PC			// pc
@@ -97,6 +101,7 @@ var tokens = [...]string{
 	COMMENT:	"COMMENT",
 
 	IDENT:		"IDENT",
+	AT:			"AT",
 	INT:			"INT",
 	CHAR:		"CHAR",
 	STRING:		"STRING",
@@ -124,11 +129,12 @@ var tokens = [...]string{
 	LOR:			"||",
 	NOT:			"!",
 
+	ASSIGN:		"=",
+
 	COMMA:		",",
-	SEMI:		";",
 	COLON:		":",
+	TERM:		"::",
 
-	AT:			"@",
 	NEXT:		":+",
 	PREV:		":-",
 
@@ -144,6 +150,7 @@ var tokens = [...]string{
 	ADDRREG_W:	"ADDRREG_W",
 	DATAREG_L:	"DATAREG_L",
 	ADDRREG_L:	"ADDRREG_L",
+	DIRECTIVE:	"DIRECTIVE",
 
 	PC:			"pc",
 	USP:			"usp",
@@ -156,10 +163,21 @@ var tokens = [...]string{
 	MOD:		".mod",
 }
 
+// Directives lists every directive, all of which are scanned as DIRECTIVE.
+var Directives = []string{
+	".dc.b", ".dc.w", ".dc.l",		// define constants
+	".ds.b", ".ds.w", ".ds.l",		// define storage
+	".equ", ".equr",
+	".org", ".section", ".code", ".data", ".bss",		// sections
+	".include", ".once", ".incbin",
+	".macro", ".endm",
+	".if", ".elseif", ".else", ".endif", ".ifdef", ".ifndef",		// conditional assembly
+}
+
 var keywords map[string]Token
 
 func init() {
-	keywords = make(map[string]Token, (len(core.Opcodes) * 5) + (8 * 6) + 3 + int(keywordEnd - keywordClassEnd))
+	keywords = make(map[string]Token, (len(core.Opcodes) * 5) + (8 * 6) + 3 + len(Directives) + int(keywordEnd - keywordClassEnd))
 	for _, op := range core.Opcodes {
 		n := op.Name()
 		keywords[n] = OPCODE
@@ -182,6 +200,9 @@ func init() {
 	keywords["sp"] = ADDRREG
 	keywords["sp.w"] = ADDRREG_W
 	keywords["sp.l"] = ADDRREG_L
+	for _, d := range Directives {
+		keywords[d] = DIRECTIVE
+	}
 	for t := keywordClassEnd + 1; t < keywordEnd; t++ {
 		keywords[tokens[t]] = t
 	}
@@ -196,6 +217,17 @@ func Lookup(str string) Token {
 	return IDENT
 }
 
+// LookupFold is like Lookup, but ignores case, so MOVE.L and Move.l are both the same as move.l.
+// If str is a keyword, LookupFold also returns its canonical spelling, which is always lowercase; otherwise, it returns str unchanged.
+func LookupFold(str string) (t Token, canon string) {
+	lower := strings.ToLower(str)
+	t, ok := keywords[lower]
+	if ok {
+		return t, lower
+	}
+	return IDENT, str
+}
+
 // IsLiteral returns whether t is a literal.
 func (t Token) IsLiteral() bool {
 	return t > literalBegin && t < literalEnd